@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/metal-stack/metal-apiserver/pkg/db/generic"
 	"github.com/urfave/cli/v3"
@@ -105,3 +106,22 @@ func newDatastoreCmd() *cli.Command {
 		},
 	}
 }
+
+// createDatastore connects to the datastore for commands which operate on the entities directly.
+func createDatastore(cmd *cli.Command, log *slog.Logger) (generic.Datastore, error) {
+	connectOpts := rethinkdb.ConnectOpts{
+		Addresses:  cmd.StringSlice(rethinkdbAddressesFlag.Name),
+		Database:   cmd.String(rethinkdbDBNameFlag.Name),
+		Username:   cmd.String(rethinkdbUserFlag.Name),
+		Password:   cmd.String(rethinkdbPasswordFlag.Name),
+		InitialCap: 10,
+		MaxOpen:    20,
+	}
+
+	ds, err := generic.New(log.WithGroup("datastore"), connectOpts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create datastore: %w", err)
+	}
+
+	return ds, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/metal-stack/metal-apiserver/pkg/redact"
 	"github.com/urfave/cli/v3"
 )
 
@@ -112,6 +113,11 @@ var (
 		Usage:   "skip tls verification when talking to the oidc provider",
 		Sources: cli.EnvVars("OIDC_TLS_SKIP_VERIFY"),
 	}
+	loginProvidersConfigFlag = &cli.StringFlag{
+		Name:    "login-providers-config",
+		Usage:   "path to a yaml file which defines additional named oidc and ldap login providers, each with its own unique-user-key, allowed redirect urls and tenant mappings",
+		Sources: cli.EnvVars("LOGIN_PROVIDERS_CONFIG"),
+	}
 	logLevelFlag = &cli.StringFlag{
 		Name:    "log-level",
 		Value:   "info",
@@ -319,6 +325,11 @@ Can not be changed after initial creation.
 		Usage:   "the maximum requests per minute for unauthenticated api access",
 		Sources: cli.EnvVars("MAX_UNAUTHENTICATED_PER_MINUTE"),
 	}
+	rateLimitPolicyFlag = &cli.StringFlag{
+		Name:    "rate-limit-policy",
+		Usage:   "path to a yaml file which defines token-bucket rate-limits per procedure group, accounted per token and per tenant. if not provided, max-requests-per-minute is applied per token",
+		Sources: cli.EnvVars("RATE_LIMIT_POLICY"),
+	}
 	ipamGrpcEndpointFlag = &cli.StringFlag{
 		Name:    "ipam-grpc-endpoint",
 		Value:   "http://ipam:9090",
@@ -362,6 +373,12 @@ Can not be changed after initial creation.
 		Usage:   "toggle if headscale should be enabled",
 		Sources: cli.EnvVars("HEADSCALE_ENABLED"),
 	}
+	headscaleReconcileIntervalFlag = &cli.DurationFlag{
+		Name:    "headscale-reconcile-interval",
+		Value:   time.Minute,
+		Usage:   "interval in which the vpn state of machines is reconciled with the headscale nodes",
+		Sources: cli.EnvVars("HEADSCALE_RECONCILE_INTERVAL"),
+	}
 	// End Headscale
 	componentExpirationFlag = &cli.DurationFlag{
 		Name:    "component-expiration",
@@ -369,6 +386,65 @@ Can not be changed after initial creation.
 		Usage:   "duration after which inactive component entries are removed",
 		Sources: cli.EnvVars("COMPONENT_EXPIRATION"),
 	}
+	imageRequireChecksumFlag = &cli.BoolFlag{
+		Name:    "image-require-checksum",
+		Value:   false,
+		Usage:   "reject images on create and update if no valid md5 checksum file exists next to the image url",
+		Sources: cli.EnvVars("IMAGE_REQUIRE_CHECKSUM"),
+	}
+	imageVerifyIntervalFlag = &cli.DurationFlag{
+		Name:    "image-verify-interval",
+		Value:   0,
+		Usage:   "interval in which the urls of all images are verified again and unreachable images are marked, disabled if zero",
+		Sources: cli.EnvVars("IMAGE_VERIFY_INTERVAL"),
+	}
+	capacitySnapshotIntervalFlag = &cli.DurationFlag{
+		Name:    "capacity-snapshot-interval",
+		Value:   0,
+		Usage:   "interval in which the capacity of all partitions is snapshotted and compared against the capacity watermarks, disabled if zero",
+		Sources: cli.EnvVars("CAPACITY_SNAPSHOT_INTERVAL"),
+	}
+	machineTimelineCleanupIntervalFlag = &cli.DurationFlag{
+		Name:    "machine-timeline-cleanup-interval",
+		Value:   24 * time.Hour,
+		Usage:   "interval in which machine timeline entries exceeding the retention are deleted, disabled if zero",
+		Sources: cli.EnvVars("MACHINE_TIMELINE_CLEANUP_INTERVAL"),
+	}
+	machineTimelineRetentionFlag = &cli.DurationFlag{
+		Name:    "machine-timeline-retention",
+		Value:   90 * 24 * time.Hour,
+		Usage:   "duration after which machine timeline entries are deleted, kept forever if zero",
+		Sources: cli.EnvVars("MACHINE_TIMELINE_RETENTION"),
+	}
+	capacitySnapshotRetentionFlag = &cli.DurationFlag{
+		Name:    "capacity-snapshot-retention",
+		Value:   90 * 24 * time.Hour,
+		Usage:   "duration after which capacity snapshots are deleted, kept forever if zero",
+		Sources: cli.EnvVars("CAPACITY_SNAPSHOT_RETENTION"),
+	}
+	capacityWebhookURLFlag = &cli.StringFlag{
+		Name:    "capacity-webhook-url",
+		Value:   "",
+		Usage:   "url which receives a json event when the free machines of a size in a partition drop below their watermark, requires --capacity-snapshot-interval, undelivered events are retried",
+		Sources: cli.EnvVars("CAPACITY_WEBHOOK_URL"),
+	}
+	capacityWatermarksFlag = &cli.StringSliceFlag{
+		Name:    "capacity-watermarks",
+		Usage:   "minimum free machines of a size below which the capacity is reported as low, in the form <size>=<minimum-free> or <size>@<partition>=<minimum-free>, a watermark with partition takes precedence",
+		Sources: cli.EnvVars("CAPACITY_WATERMARKS"),
+	}
+	consoleTicketDeliveryFlag = &cli.BoolFlag{
+		Name:    "console-ticket-delivery",
+		Value:   false,
+		Usage:   "publish issued console tickets on a redis channel per partition the serial console gateways subscribe to",
+		Sources: cli.EnvVars("CONSOLE_TICKET_DELIVERY"),
+	}
+	redactFieldsFlag = &cli.StringSliceFlag{
+		Name:    "redact-fields",
+		Value:   redact.DefaultFields,
+		Usage:   "proto fields which are masked in request logs and audit traces, either by field name (e.g. password) or fully qualified (e.g. metalstack.api.v2.MachineBMC.password)",
+		Sources: cli.EnvVars("REDACT_FIELDS"),
+	}
 	secureCookieFlag = &cli.BoolFlag{
 		Name:    "secure-cookie",
 		Value:   true,
@@ -21,10 +21,16 @@ import (
 	"github.com/metal-stack/metal-apiserver/pkg/async/queue"
 	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/certs"
+	"github.com/metal-stack/metal-apiserver/pkg/console"
 	"github.com/metal-stack/metal-apiserver/pkg/db/generic"
 	"github.com/metal-stack/metal-apiserver/pkg/headscale"
+	"github.com/metal-stack/metal-apiserver/pkg/imageverify"
+	"github.com/metal-stack/metal-apiserver/pkg/invite"
+	ratelimiter "github.com/metal-stack/metal-apiserver/pkg/rate-limiter"
+	"github.com/metal-stack/metal-apiserver/pkg/redact"
 	"github.com/metal-stack/metal-apiserver/pkg/repository"
 	"github.com/metal-stack/metal-apiserver/pkg/service"
+	authservice "github.com/metal-stack/metal-apiserver/pkg/service/auth"
 	"github.com/metal-stack/metal-apiserver/pkg/test"
 	"github.com/metal-stack/metal-apiserver/pkg/token"
 	tenant "github.com/metal-stack/tenant-api/go/client"
@@ -71,6 +77,7 @@ func newServeCmd() *cli.Command {
 			providerTenantFlag,
 			maxRequestsPerMinuteFlag,
 			maxRequestsPerMinuteUnauthenticatedFlag,
+			rateLimitPolicyFlag,
 			ipamGrpcEndpointFlag,
 			frontEndUrlFlag,
 			oidcClientIdFlag,
@@ -79,14 +86,26 @@ func newServeCmd() *cli.Command {
 			oidcEndSessionUrlFlag,
 			oidcUniqueUserKeyFlag,
 			oidcTLSSkipVerifyFlag,
+			loginProvidersConfigFlag,
 			bmcSuperuserPasswordFlag,
 			headscaleAddressFlag,
 			headscaleControlplaneAddressFlag,
 			headscaleApikeyFlag,
 			headscaleEnabledFlag,
+			headscaleReconcileIntervalFlag,
 			componentExpirationFlag,
+			imageRequireChecksumFlag,
+			imageVerifyIntervalFlag,
+			capacitySnapshotIntervalFlag,
+			machineTimelineCleanupIntervalFlag,
+			machineTimelineRetentionFlag,
+			capacitySnapshotRetentionFlag,
+			capacityWebhookURLFlag,
+			capacityWatermarksFlag,
+			consoleTicketDeliveryFlag,
 			secureCookieFlag,
 			redirectUrlsFlag,
+			redactFieldsFlag,
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			log, err := createLogger(cmd)
@@ -94,11 +113,43 @@ func newServeCmd() *cli.Command {
 				return fmt.Errorf("unable to create logger %w", err)
 			}
 
+			redactor := redact.New(cmd.StringSlice(redactFieldsFlag.Name)...)
+
+			var loginProviders *authservice.ProvidersConfig
+			if path := cmd.String(loginProvidersConfigFlag.Name); path != "" {
+				loginProviders, err = authservice.LoadProvidersConfig(path)
+				if err != nil {
+					return err
+				}
+			}
+
+			// low capacity is only detected when snapshots are taken, without them no event would ever be sent
+			if cmd.String(capacityWebhookURLFlag.Name) != "" && cmd.Duration(capacitySnapshotIntervalFlag.Name) <= 0 {
+				return fmt.Errorf("--%s requires --%s to be set", capacityWebhookURLFlag.Name, capacitySnapshotIntervalFlag.Name)
+			}
+
+			capacityWatermarks, err := repository.ParseCapacityWatermarks(cmd.StringSlice(capacityWatermarksFlag.Name))
+			if err != nil {
+				return err
+			}
+
+			var rateLimitPolicy *ratelimiter.Policy
+			if path := cmd.String(rateLimitPolicyFlag.Name); path != "" {
+				rateLimitPolicy, err = ratelimiter.LoadPolicy(path)
+				if err != nil {
+					return err
+				}
+			}
+
 			auditSearchBackend, auditBackends, err := createAuditingClient(cmd, log)
 			if err != nil {
 				return fmt.Errorf("unable to create auditing client: %w", err)
 			}
 
+			for i, backend := range auditBackends {
+				auditBackends[i] = redact.NewAuditing(backend, redactor)
+			}
+
 			ipam, err := createIpamClient(ctx, cmd, log)
 			if err != nil {
 				return fmt.Errorf("unable to create ipam client: %w", err)
@@ -148,6 +199,11 @@ func newServeCmd() *cli.Command {
 				return fmt.Errorf("unable to create datastore: %w", err)
 			}
 
+			var consoleBackend console.Backend
+			if cmd.Bool(consoleTicketDeliveryFlag.Name) {
+				consoleBackend = console.NewRedisBackend(redisConfig.TokenClient)
+			}
+
 			var (
 				task  = task.NewClient(log, redisConfig.AsyncClient)
 				queue = queue.New(log, redisConfig.QueueClient)
@@ -160,7 +216,11 @@ func newServeCmd() *cli.Command {
 					Queue:                 queue,
 					Component:             redisConfig.ComponentClient,
 					Auditing:              auditSearchBackend,
+					AuditBackends:         auditBackends,
 					HeadscaleClient:       hc,
+					ImageVerifier: imageverify.New(imageverify.Config{
+						RequireChecksum: cmd.Bool(imageRequireChecksumFlag.Name),
+					}),
 					TokenConfig: repository.TokenConfig{
 						TokenStore: token.NewRedisStore(redisConfig.TokenClient),
 						CertStore: certs.NewRedisStore(&certs.Config{
@@ -169,6 +229,23 @@ func newServeCmd() *cli.Command {
 						ProviderTenant: cmd.String(providerTenantFlag.Name),
 						Issuer:         cmd.String(serverHttpUrlFlag.Name),
 					},
+					InviteConfig: repository.InviteConfig{
+						ProjectInviteStore: invite.NewProjectRedisStore(redisConfig.InviteClient),
+						TenantInviteStore:  invite.NewTenantRedisStore(redisConfig.InviteClient),
+					},
+					ConsoleConfig: repository.ConsoleConfig{
+						TicketStore: console.NewRedisStore(redisConfig.TokenClient),
+						Backend:     consoleBackend,
+					},
+					CapacityConfig: repository.CapacityConfig{
+						SnapshotRetention: cmd.Duration(capacitySnapshotRetentionFlag.Name),
+						SnapshotInterval:  cmd.Duration(capacitySnapshotIntervalFlag.Name),
+						WebhookURL:        cmd.String(capacityWebhookURLFlag.Name),
+						Watermarks:        capacityWatermarks,
+					},
+					TimelineConfig: repository.TimelineConfig{
+						Retention: cmd.Duration(machineTimelineRetentionFlag.Name),
+					},
 				})
 				stage = cmd.String(stageFlag.Name)
 			)
@@ -198,17 +275,24 @@ func newServeCmd() *cli.Command {
 				ProviderTenant:                      cmd.String(providerTenantFlag.Name),
 				MaxRequestsPerMinuteToken:           cmd.Int(maxRequestsPerMinuteFlag.Name),
 				MaxRequestsPerMinuteUnauthenticated: cmd.Int(maxRequestsPerMinuteUnauthenticatedFlag.Name),
+				RateLimitPolicy:                     rateLimitPolicy,
 				OIDCClientID:                        cmd.String(oidcClientIdFlag.Name),
 				OIDCClientSecret:                    cmd.String(oidcClientSecretFlag.Name),
 				OIDCDiscoveryURL:                    cmd.String(oidcDiscoveryUrlFlag.Name),
 				OIDCEndSessionURL:                   cmd.String(oidcEndSessionUrlFlag.Name),
 				OIDCUniqueUserKey:                   cmd.String(oidcUniqueUserKeyFlag.Name),
 				OIDCTLSSkipVerify:                   cmd.Bool(oidcTLSSkipVerifyFlag.Name),
+				LoginProviders:                      loginProviders,
 				IsStageDev:                          strings.EqualFold(stage, stageDEV),
 				SecureCookie:                        cmd.Bool(secureCookieFlag.Name),
 				BMCSuperuserPassword:                cmd.String(bmcSuperuserPasswordFlag.Name),
 				HeadscaleClient:                     hc,
+				VPNReconcileInterval:                cmd.Duration(headscaleReconcileIntervalFlag.Name),
 				ComponentExpiration:                 cmd.Duration(componentExpirationFlag.Name),
+				ImageVerifyInterval:                 cmd.Duration(imageVerifyIntervalFlag.Name),
+				CapacitySnapshotInterval:            cmd.Duration(capacitySnapshotIntervalFlag.Name),
+				MachineTimelineCleanupInterval:      cmd.Duration(machineTimelineCleanupIntervalFlag.Name),
+				Redactor:                            redactor,
 			}
 
 			err = repo.Tenant().AdditionalMethods().EnsureProviderTenant(ctx, c.ProviderTenant)
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -10,11 +11,15 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 
 	taskserver "github.com/metal-stack/metal-apiserver/pkg/async/task/server"
 	"github.com/metal-stack/metal-apiserver/pkg/service"
+	componentadmin "github.com/metal-stack/metal-apiserver/pkg/service/admin/component"
+	partitionadmin "github.com/metal-stack/metal-apiserver/pkg/service/admin/partition"
+	"github.com/metal-stack/metal-apiserver/pkg/vpn"
 )
 
 type server struct {
@@ -68,6 +73,12 @@ func (s *server) Run(ctx context.Context) error {
 		}
 	}()
 
+	prometheus.MustRegister(componentadmin.NewMissingCollector(s.log, s.c.Repository))
+	if s.c.CapacitySnapshotInterval > 0 {
+		// low capacity is evaluated on the latest snapshot
+		prometheus.MustRegister(partitionadmin.NewLowCapacityCollector(s.log, s.c.Repository))
+	}
+
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", promhttp.Handler())
 	ms := &http.Server{
@@ -93,9 +104,33 @@ func (s *server) Run(ctx context.Context) error {
 		}
 	}()
 
+	taskScheduler, err := taskserver.NewScheduler(s.log, s.c.RedisConfig.AsyncClient, taskserver.SchedulerConfig{
+		ImageVerifyInterval:            s.c.ImageVerifyInterval,
+		CapacitySnapshotInterval:       s.c.CapacitySnapshotInterval,
+		MachineTimelineCleanupInterval: s.c.MachineTimelineCleanupInterval,
+	})
+	if err != nil {
+		return err
+	}
+	err = taskScheduler.Start()
+	if err != nil {
+		return fmt.Errorf("unable to start asynq scheduler: %w", err)
+	}
+
+	if s.c.HeadscaleClient != nil {
+		reconciler := vpn.NewReconciler(vpn.ReconcilerConfig{
+			Log:       s.log,
+			Repo:      s.c.Repository,
+			Datastore: s.c.Datastore,
+			Interval:  s.c.VPNReconcileInterval,
+		})
+		go reconciler.Run(ctx)
+	}
+
 	<-signals
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
+	taskScheduler.Shutdown()
 	taskServer.Shutdown()
 	return apiServer.Shutdown(ctx)
 }
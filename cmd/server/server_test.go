@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"strconv"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/certs"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v3"
 )
@@ -16,7 +21,7 @@ func Test_newServeCmd(t *testing.T) {
 	args := []string{"-h"}
 
 	cmd := newServeCmd()
-	require.Len(t, cmd.Flags, 48)
+	require.Len(t, cmd.Flags, 61)
 
 	app.Commands = []*cli.Command{cmd}
 	err := app.Run(context.Background(), args)
@@ -46,3 +51,48 @@ func Test_newTokenCmd(t *testing.T) {
 	err := app.Run(context.Background(), args)
 	require.NoError(t, err)
 }
+
+func Test_newSigningKeysCmd(t *testing.T) {
+	var (
+		ctx   = t.Context()
+		s     = miniredis.RunT(t)
+		store = certs.NewRedisStore(&certs.Config{
+			RedisClient: redis.NewClient(&redis.Options{Addr: s.Addr()}),
+		})
+		run = func(args ...string) string {
+			var out bytes.Buffer
+			app := &cli.Command{Writer: &out, Commands: []*cli.Command{newSigningKeysCmd()}}
+			err := app.Run(ctx, append([]string{"app", "signing-keys", "--redis-addr", s.Addr()}, args...))
+			require.NoError(t, err)
+			return out.String()
+		}
+	)
+
+	_, err := store.LatestPrivate(ctx)
+	require.NoError(t, err)
+
+	keys, err := store.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	first := keys[0]
+
+	out := run("list")
+	require.Contains(t, out, strconv.FormatInt(first.Serial, 10))
+
+	out = run("rotate")
+	require.NotContains(t, out, strconv.FormatInt(first.Serial, 10))
+
+	keys, err = store.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.False(t, keys[0].Latest)
+	require.True(t, keys[1].Latest)
+
+	out = run("revoke", "--serial", strconv.FormatInt(first.Serial, 10))
+	require.Contains(t, out, "revoked signing key")
+
+	keys, err = store.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotEqual(t, first.Serial, keys[0].Serial)
+}
@@ -110,6 +110,9 @@ func newTokenCmd() *cli.Command {
 			secretNameFlag,
 			tokensCreateConfigFileFlag,
 		},
+		Commands: []*cli.Command{
+			newSigningKeysCmd(),
+		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			log, err := createLogger(cmd)
 			if err != nil {
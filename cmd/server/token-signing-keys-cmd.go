@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/metal-stack/metal-apiserver/pkg/certs"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	signingKeySerialFlag = &cli.Int64Flag{
+		Name:     "serial",
+		Usage:    "serial of the signing key",
+		Required: true,
+	}
+)
+
+func newSigningKeysCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "signing-keys",
+		Usage: "manage the keys which are used to sign api tokens",
+		Flags: []cli.Flag{
+			logLevelFlag,
+			redisAddrFlag,
+			redisPasswordFlag,
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "lists all signing keys which are accepted for token validation",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					store, err := createCertStore(ctx, cmd)
+					if err != nil {
+						return err
+					}
+
+					keys, err := store.Keys(ctx)
+					if err != nil {
+						return err
+					}
+
+					return printSigningKeys(cmd.Root().Writer, keys...)
+				},
+			},
+			{
+				Name:  "rotate",
+				Usage: "issues a new signing key immediately, tokens signed by previous keys stay valid",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					store, err := createCertStore(ctx, cmd)
+					if err != nil {
+						return err
+					}
+
+					key, err := store.Rotate(ctx)
+					if err != nil {
+						return err
+					}
+
+					return printSigningKeys(cmd.Root().Writer, key)
+				},
+			},
+			{
+				Name:  "revoke",
+				Usage: "revokes a compromised signing key, all tokens signed by this key are rejected",
+				Flags: []cli.Flag{
+					signingKeySerialFlag,
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					store, err := createCertStore(ctx, cmd)
+					if err != nil {
+						return err
+					}
+
+					serial := cmd.Int64(signingKeySerialFlag.Name)
+
+					err = store.Revoke(ctx, serial)
+					if err != nil {
+						return err
+					}
+
+					_, err = fmt.Fprintf(cmd.Root().Writer, "revoked signing key %d\n", serial)
+					return err
+				},
+			},
+		},
+	}
+}
+
+func createCertStore(ctx context.Context, cmd *cli.Command) (certs.CertStore, error) {
+	log, err := createLogger(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create logger %w", err)
+	}
+
+	tokenRedisClient, _, err := createRedisClient(ctx, cmd, log, redisDatabaseTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return certs.NewRedisStore(&certs.Config{
+		RedisClient: tokenRedisClient,
+	}), nil
+}
+
+func printSigningKeys(w io.Writer, keys ...*certs.Key) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "SERIAL\tISSUED\tEXPIRES\tSIGNING")
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%t\n", k.Serial, k.IssuedAt.Format(time.RFC3339), k.ExpiresAt.Format(time.RFC3339), k.Latest)
+	}
+
+	return tw.Flush()
+}
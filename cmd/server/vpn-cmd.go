@@ -3,13 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
-	"github.com/metal-stack/metal-apiserver/pkg/db/generic"
 	"github.com/metal-stack/metal-apiserver/pkg/headscale"
 	"github.com/metal-stack/metal-apiserver/pkg/repository"
 	"github.com/metal-stack/metal-apiserver/pkg/vpn"
 	"github.com/urfave/cli/v3"
-	"gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
 func newVPNCmd() *cli.Command {
@@ -40,36 +39,11 @@ func newVPNCmd() *cli.Command {
 						return nil
 					}
 
-					hc, err := headscale.NewClient(headscale.Config{
-						Log:           log,
-						Apikey:        cmd.String(headscaleApikeyFlag.Name),
-						Endpoint:      cmd.String(headscaleAddressFlag.Name),
-						ControllerURL: cmd.String(headscaleControlplaneAddressFlag.Name),
-					})
+					repo, err := createVPNRepository(cmd, log)
 					if err != nil {
 						return err
 					}
 
-					connectOpts := rethinkdb.ConnectOpts{
-						Addresses:  cmd.StringSlice(rethinkdbAddressesFlag.Name),
-						Database:   cmd.String(rethinkdbDBNameFlag.Name),
-						Username:   cmd.String(rethinkdbUserFlag.Name),
-						Password:   cmd.String(rethinkdbPasswordFlag.Name),
-						InitialCap: 10,
-						MaxOpen:    20,
-					}
-
-					ds, err := generic.New(log.WithGroup("datastore"), connectOpts)
-					if err != nil {
-						return fmt.Errorf("unable to create datastore: %w", err)
-					}
-
-					repo := repository.New(repository.Config{
-						Log:             log,
-						Datastore:       ds,
-						HeadscaleClient: hc,
-					})
-
 					_, err = vpn.EvaluateVPNConnected(ctx, log, repo)
 					return err
 				},
@@ -77,3 +51,26 @@ func newVPNCmd() *cli.Command {
 		},
 	}
 }
+
+func createVPNRepository(cmd *cli.Command, log *slog.Logger) (*repository.Store, error) {
+	hc, err := headscale.NewClient(headscale.Config{
+		Log:           log,
+		Apikey:        cmd.String(headscaleApikeyFlag.Name),
+		Endpoint:      cmd.String(headscaleAddressFlag.Name),
+		ControllerURL: cmd.String(headscaleControlplaneAddressFlag.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := createDatastore(cmd, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.New(repository.Config{
+		Log:             log,
+		Datastore:       ds,
+		HeadscaleClient: hc,
+	}), nil
+}
@@ -175,6 +175,7 @@ func (c *Client) listQueueTasks(queue string) ([]*asynq.TaskInfo, error) {
 	for _, listFn := range []taskListFn{
 		c.inspector.ListActiveTasks,
 		c.inspector.ListPendingTasks,
+		c.inspector.ListScheduledTasks,
 		c.inspector.ListRetryTasks,
 		c.inspector.ListArchivedTasks,
 		c.inspector.ListCompletedTasks,
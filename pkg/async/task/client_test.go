@@ -5,8 +5,10 @@ import (
 	"log/slog"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
 	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
@@ -196,3 +198,31 @@ func TestClient_Informers(t *testing.T) {
 	require.Equal(t, taskInfo.ID, taskList[0].ID)
 
 }
+
+func TestClient_ListScheduled(t *testing.T) {
+	var (
+		log = slog.Default()
+		r   = miniredis.RunT(t)
+		rc  = redis.NewClient(&redis.Options{Addr: r.Addr()})
+		c   = task.NewClient(log, rc)
+	)
+
+	scheduled, err := c.NewTask(&task.MachineDeletePayload{
+		UUID:           "machine-uuid",
+		AllocationUUID: "allocation-uuid",
+	}, asynq.ProcessIn(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, asynq.TaskStateScheduled, scheduled.State)
+
+	taskList, err := c.List(nil)
+	require.NoError(t, err)
+	require.Len(t, taskList, 1)
+	require.Equal(t, scheduled.ID, taskList[0].ID)
+	require.Equal(t, asynq.TaskStateScheduled, taskList[0].State)
+
+	require.NoError(t, c.DeleteTask("default", scheduled.ID))
+
+	taskList, err = c.List(nil)
+	require.NoError(t, err)
+	require.Empty(t, taskList)
+}
@@ -3,15 +3,24 @@ package task
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
 
 const (
-	TypeIpDelete          TaskType = "ip:delete"
-	TypeNetworkDelete     TaskType = "network:delete"
-	TypeMachineDelete     TaskType = "machine:delete"
-	TypeMachineBMCCommand TaskType = "machine:bmc-command"
+	TypeIpDelete                   TaskType = "ip:delete"
+	TypeNetworkDelete              TaskType = "network:delete"
+	TypeMachineDelete              TaskType = "machine:delete"
+	TypeMachineBMCCommand          TaskType = "machine:bmc-command"
+	TypeAccessRevoke               TaskType = "access:revoke"
+	TypeImageVerify                TaskType = "image:verify"
+	TypePartitionCapacitySnapshot  TaskType = "partition:capacity-snapshot"
+	TypePartitionCapacityEvent     TaskType = "partition:capacity-event"
+	TypeMachineBulkBMCCommand      TaskType = "machine:bulk-bmc-command"
+	TypeMachineScheduledBMCCommand TaskType = "machine:scheduled-bmc-command"
+	TypeMachineTimelineCleanup     TaskType = "machine:timeline-cleanup"
+	TypeMachineRemediation         TaskType = "machine:remediation"
 )
 
 type (
@@ -59,10 +68,86 @@ type (
 		CommandID string `json:"command_id"`
 	}
 
+	MachineBulkBMCCommandPayload struct {
+		// Machines where the command should be executed against
+		Machines []BulkBMCCommandMachine `json:"machines,omitempty"`
+		// The actual command
+		Command string `json:"command,omitempty"`
+		// Concurrency is the maximum number of commands which are executed at the same time in a partition
+		Concurrency int `json:"concurrency,omitempty"`
+		// Interval is the minimum duration between two commands which are started in the same partition
+		Interval time.Duration `json:"interval,omitempty"`
+	}
+
+	MachineScheduledBMCCommandPayload struct {
+		// ScheduleID identifies the schedule, it is the same for all occurrences of a recurring command
+		ScheduleID string `json:"schedule_id"`
+		// Query is the protojson encoded machine query which selects the machines when the command is executed
+		Query json.RawMessage `json:"query"`
+		// The actual command
+		Command string `json:"command,omitempty"`
+		// Concurrency is the maximum number of commands which are executed at the same time in a partition
+		Concurrency int `json:"concurrency,omitempty"`
+		// Interval is the minimum duration between two commands which are started in the same partition
+		Interval time.Duration `json:"interval,omitempty"`
+		// Cron is the cron expression of a recurring command, if empty the command is executed only once
+		Cron string `json:"cron,omitempty"`
+	}
+
+	BulkBMCCommandMachine struct {
+		// UUID of the machine
+		UUID string `json:"uuid"`
+		// Partition where the machine resides
+		Partition string `json:"partition"`
+		// Skipped is the reason why the command is not executed against this machine
+		Skipped string `json:"skipped,omitempty"`
+	}
+
 	BMCCommandDonePayload struct {
 		Error *string `json:"error,omitempty"`
 	}
 
+	AccessRevokePayload struct {
+		// Project is the project for which the access should be revoked
+		Project string `json:"project,omitempty"`
+		// Tenant is the tenant for which the access should be revoked
+		Tenant string `json:"tenant,omitempty"`
+		// User is the user whose access was removed, if empty the access is revoked for all users because the project or tenant was deleted
+		User string `json:"user,omitempty"`
+	}
+
+	// ImageVerifyPayload triggers the verification of the urls of all images
+	ImageVerifyPayload struct{}
+
+	// PartitionCapacitySnapshotPayload triggers a snapshot of the capacity of all partitions
+	PartitionCapacitySnapshotPayload struct{}
+
+	// PartitionCapacityEventPayload is sent to the capacity webhook, the task is retried until the webhook accepts it
+	PartitionCapacityEventPayload struct {
+		// Partition in which the free machines dropped below the watermark
+		Partition string `json:"partition"`
+		// Size whose free machines dropped below the watermark
+		Size string `json:"size"`
+		// Free is the number of free machines
+		Free int64 `json:"free"`
+		// MinimumFree is the watermark
+		MinimumFree int64 `json:"minimum_free"`
+		// Time at which the capacity was snapshotted
+		Time time.Time `json:"time"`
+	}
+
+	// MachineTimelineCleanupPayload triggers the deletion of machine timeline entries which exceed the retention
+	MachineTimelineCleanupPayload struct{}
+
+	MachineRemediationPayload struct {
+		// UUID of the machine on which the trigger occurred
+		UUID string `json:"uuid,omitempty"`
+		// Trigger is either crashloop or failed-machine-reclaim
+		Trigger string `json:"trigger,omitempty"`
+		// Count is how often the trigger occurred in a row
+		Count int `json:"count,omitempty"`
+	}
+
 	MachineAllocationPayload struct {
 		// UUID of the machine which was allocated and trigger the machine installation
 		UUID string `json:"uuid,omitempty"`
@@ -85,6 +170,38 @@ func (p *MachineBMCCommandPayload) Type() TaskType {
 	return TypeMachineBMCCommand
 }
 
+func (p *MachineBulkBMCCommandPayload) Type() TaskType {
+	return TypeMachineBulkBMCCommand
+}
+
+func (p *MachineScheduledBMCCommandPayload) Type() TaskType {
+	return TypeMachineScheduledBMCCommand
+}
+
+func (p *AccessRevokePayload) Type() TaskType {
+	return TypeAccessRevoke
+}
+
+func (p *ImageVerifyPayload) Type() TaskType {
+	return TypeImageVerify
+}
+
+func (p *PartitionCapacitySnapshotPayload) Type() TaskType {
+	return TypePartitionCapacitySnapshot
+}
+
+func (p *PartitionCapacityEventPayload) Type() TaskType {
+	return TypePartitionCapacityEvent
+}
+
+func (p *MachineTimelineCleanupPayload) Type() TaskType {
+	return TypeMachineTimelineCleanup
+}
+
+func (p *MachineRemediationPayload) Type() TaskType {
+	return TypeMachineRemediation
+}
+
 // EncodePayload can be used to encode a task payload using json marshal.
 func EncodePayload(payload TaskPayload) ([]byte, error) {
 	encoded, err := json.Marshal(payload)
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/redis/go-redis/v9"
+)
+
+// SchedulerConfig contains the intervals of periodic tasks, tasks with an interval of zero are not scheduled.
+type SchedulerConfig struct {
+	ImageVerifyInterval            time.Duration
+	CapacitySnapshotInterval       time.Duration
+	MachineTimelineCleanupInterval time.Duration
+}
+
+// NewScheduler returns a scheduler which enqueues the periodic tasks.
+// Every replica of the api-server runs a scheduler, the tasks are enqueued as unique tasks
+// such that they are only processed once per interval.
+func NewScheduler(log *slog.Logger, redis *redis.Client, c SchedulerConfig) (*asynq.Scheduler, error) {
+	scheduler := asynq.NewSchedulerFromRedisClient(redis, nil)
+
+	periodic := []struct {
+		payload  task.TaskPayload
+		interval time.Duration
+	}{
+		{payload: &task.ImageVerifyPayload{}, interval: c.ImageVerifyInterval},
+		{payload: &task.PartitionCapacitySnapshotPayload{}, interval: c.CapacitySnapshotInterval},
+		{payload: &task.MachineTimelineCleanupPayload{}, interval: c.MachineTimelineCleanupInterval},
+	}
+
+	for _, p := range periodic {
+		if p.interval <= 0 {
+			continue
+		}
+
+		encoded, err := task.EncodePayload(p.payload)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = scheduler.Register(fmt.Sprintf("@every %s", p.interval), asynq.NewTask(string(p.payload.Type()), encoded), asynq.Unique(p.interval))
+		if err != nil {
+			return nil, fmt.Errorf("unable to schedule task %s: %w", p.payload.Type(), err)
+		}
+
+		log.Info("scheduled periodic task", "type", p.payload.Type(), "interval", p.interval)
+	}
+
+	return scheduler, nil
+}
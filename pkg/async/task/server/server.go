@@ -30,6 +30,14 @@ func NewServer(log *slog.Logger, store *repository.Store, redis *redis.Client) (
 	mux.HandleFunc(string(task.TypeNetworkDelete), store.NetworkDeleteHandleFn)
 	mux.HandleFunc(string(task.TypeMachineDelete), store.MachineDeleteHandleFn)
 	mux.HandleFunc(string(task.TypeMachineBMCCommand), store.MachineBMCCommandHandleFn)
+	mux.HandleFunc(string(task.TypeMachineBulkBMCCommand), store.MachineBulkBMCCommandHandleFn)
+	mux.HandleFunc(string(task.TypeMachineScheduledBMCCommand), store.MachineScheduledBMCCommandHandleFn)
+	mux.HandleFunc(string(task.TypeAccessRevoke), store.AccessRevokeHandleFn)
+	mux.HandleFunc(string(task.TypeImageVerify), store.ImageVerifyHandleFn)
+	mux.HandleFunc(string(task.TypePartitionCapacitySnapshot), store.PartitionCapacitySnapshotHandleFn)
+	mux.HandleFunc(string(task.TypePartitionCapacityEvent), store.PartitionCapacityEventHandleFn)
+	mux.HandleFunc(string(task.TypeMachineTimelineCleanup), store.MachineTimelineCleanupHandleFn)
+	mux.HandleFunc(string(task.TypeMachineRemediation), store.MachineRemediationHandleFn)
 
 	// ...register other handlers...
 	return srv, mux
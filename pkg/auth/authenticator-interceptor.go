@@ -33,6 +33,7 @@ type (
 	// auth is a gRPC server authorizer
 	auth struct {
 		log           *slog.Logger
+		certStore     certs.CertStore
 		certCache     *cache.Cache[any, *cacheReturn]
 		tokenStore    token.TokenStore
 		allowedIssuer []string
@@ -56,7 +57,8 @@ func NewAuthenticatorInterceptor(c Config) (*auth, error) {
 	}
 
 	return &auth{
-		log: log,
+		log:       log,
+		certStore: c.CertStore,
 		certCache: cache.New(certCacheTime, func(ctx context.Context, id any) (*cacheReturn, error) {
 			set, raw, err := c.CertStore.PublicKeys(ctx)
 			if err != nil {
@@ -72,6 +74,25 @@ func NewAuthenticatorInterceptor(c Config) (*auth, error) {
 	}, nil
 }
 
+// WatchCertStore refreshes the cached signing keys whenever keys are rotated or revoked on any
+// api-server instance, such that revoked keys are rejected immediately. It blocks until the context is done.
+func (o *auth) WatchCertStore(ctx context.Context) {
+	changes, err := o.certStore.Changes(ctx)
+	if err != nil {
+		o.log.Error("unable to watch signing key changes, revoked keys are only rejected after the cert cache expired", "error", err)
+		return
+	}
+
+	for range changes {
+		o.log.Info("signing keys changed, refreshing cert cache")
+
+		_, err := o.certCache.Refresh(ctx, nil)
+		if err != nil {
+			o.log.Error("unable to refresh cert cache", "error", err)
+		}
+	}
+}
+
 func (o *auth) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
 	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
 		return next(ctx, spec)
@@ -2,6 +2,7 @@ package certs
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -13,10 +14,12 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/metal-stack/api/go/errorutil"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -43,6 +46,27 @@ type Config struct {
 type CertStore interface {
 	LatestPrivate(ctx context.Context) (*ecdsa.PrivateKey, error)
 	PublicKeys(ctx context.Context) (jwk.Set, string, error)
+	// TODO: Keys, Rotate and Revoke are only reachable through the token signing-keys subcommands,
+	// serve them from an admin token service rpc with authorization and auditing once the api defines it.
+
+	// Keys lists all signing keys, ordered by serial.
+	Keys(ctx context.Context) ([]*Key, error)
+	// Rotate issues a new signing key immediately, previous keys stay valid for token validation.
+	Rotate(ctx context.Context) (*Key, error)
+	// Revoke removes the key with the given serial, all tokens signed by this key are rejected afterwards.
+	// If the key is currently used for signing, a new signing key is issued.
+	Revoke(ctx context.Context, serial int64) error
+	// Changes notifies about added or revoked keys on any api-server instance until the context is done.
+	Changes(ctx context.Context) (<-chan struct{}, error)
+}
+
+// Key describes a signing key
+type Key struct {
+	Serial    int64
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Latest is true for the key which is used to sign new tokens
+	Latest bool
 }
 
 type redisStore struct {
@@ -68,6 +92,10 @@ func matchPublic() string {
 	return prefix + "root_tokens_public_" + "*"
 }
 
+func channelChanges() string {
+	return prefix + "changes"
+}
+
 func NewRedisStore(c *Config) CertStore {
 	renewCertBeforeExpiration := defaultRenewalThreshold
 	if c.RenewCertBeforeExpiration != nil {
@@ -81,19 +109,14 @@ func NewRedisStore(c *Config) CertStore {
 }
 
 func (r *redisStore) LatestPrivate(ctx context.Context) (*ecdsa.PrivateKey, error) {
-	res, err := r.client.Get(ctx, keyPrivateLatest()).Result()
+	privateKey, err := r.latestPrivate(ctx)
 	if err != nil {
 		if !errors.Is(err, redis.Nil) { // this means not found
 			return nil, err
 		}
 
-		return r.setNewCert(ctx)
-	}
-
-	var privateKey privateKey
-	err = json.Unmarshal([]byte(res), &privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("unable to unmarshal private key: %w", err)
+		_, privKey, err := r.setNewCert(ctx)
+		return privKey, err
 	}
 
 	decoded, _ := pem.Decode(privateKey.Raw)
@@ -107,23 +130,39 @@ func (r *redisStore) LatestPrivate(ctx context.Context) (*ecdsa.PrivateKey, erro
 	}
 
 	if time.Until(privateKey.ExpiresAt) < r.renewCertBeforeExpiration {
-		return r.setNewCert(ctx)
+		_, privKey, err := r.setNewCert(ctx)
+		return privKey, err
 	}
 
 	return privKey, nil
 }
 
-func (r *redisStore) setNewCert(ctx context.Context) (*ecdsa.PrivateKey, error) {
+func (r *redisStore) latestPrivate(ctx context.Context) (*privateKey, error) {
+	res, err := r.client.Get(ctx, keyPrivateLatest()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var privateKey privateKey
+	err = json.Unmarshal([]byte(res), &privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal private key: %w", err)
+	}
+
+	return &privateKey, nil
+}
+
+func (r *redisStore) setNewCert(ctx context.Context) (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	now := time.Now()
 
 	cert, privKey, rawBytes, err := createRootCertificate("metal-stack", now, now.Add(2*MaxTokenExpiration))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create certificate: %w", err)
+		return nil, nil, fmt.Errorf("unable to create certificate: %w", err)
 	}
 
 	x509Key, err := x509.MarshalECPrivateKey(privKey)
 	if err != nil {
-		return nil, fmt.Errorf("unable to marshal private key: %w", err)
+		return nil, nil, fmt.Errorf("unable to marshal private key: %w", err)
 	}
 
 	pemEncoded := pem.EncodeToMemory(&pem.Block{
@@ -139,64 +178,228 @@ func (r *redisStore) setNewCert(ctx context.Context) (*ecdsa.PrivateKey, error)
 		Serial:    cert.SerialNumber.Int64(),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to encode signing certificate: %w", err)
+		return nil, nil, fmt.Errorf("unable to encode signing certificate: %w", err)
 	}
 
 	pipe := r.client.TxPipeline()
 
 	_ = pipe.Set(ctx, keyPrivateLatest(), string(encoded), expires)
 	_ = pipe.Set(ctx, keyPublic(), string(rawBytes), expires)
+	_ = pipe.Publish(ctx, channelChanges(), cert.SerialNumber.String())
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to store certificate: %w", err)
+		return nil, nil, fmt.Errorf("unable to store certificate: %w", err)
 	}
 
-	return privKey, nil
+	return cert, privKey, nil
 }
 
 func (r *redisStore) PublicKeys(ctx context.Context) (jwk.Set, string, error) {
+	set := jwk.NewSet()
+
+	certs, err := r.publicCerts(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, c := range certs {
+		key, err := jwk.Import(c.PublicKey)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to add public key: %w", err)
+		}
+
+		err = set.AddKey(key)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	res, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to marshal json: %w", err)
+	}
+
+	return set, string(res), nil
+}
+
+func (r *redisStore) Keys(ctx context.Context) ([]*Key, error) {
+	certs, err := r.publicCerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latestSerial int64
+	latest, err := r.latestPrivate(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	if latest != nil {
+		latestSerial = latest.Serial
+	}
+
+	var keys []*Key
+	for _, c := range certs {
+		keys = append(keys, &Key{
+			Serial:    c.SerialNumber.Int64(),
+			IssuedAt:  c.NotBefore,
+			ExpiresAt: c.NotAfter,
+			Latest:    c.SerialNumber.Int64() == latestSerial,
+		})
+	}
+
+	slices.SortFunc(keys, func(a, b *Key) int {
+		return cmp.Compare(a.Serial, b.Serial)
+	})
+
+	return keys, nil
+}
+
+func (r *redisStore) Rotate(ctx context.Context) (*Key, error) {
+	cert, _, err := r.setNewCert(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Serial:    cert.SerialNumber.Int64(),
+		IssuedAt:  cert.NotBefore,
+		ExpiresAt: cert.NotAfter,
+		Latest:    true,
+	}, nil
+}
+
+func (r *redisStore) Revoke(ctx context.Context, serial int64) error {
 	var (
-		set  = jwk.NewSet()
-		iter = r.client.Scan(ctx, 0, matchPublic(), 0).Iterator()
+		found bool
+		iter  = r.client.Scan(ctx, 0, matchPublic(), 0).Iterator()
 	)
 
 	for iter.Next(ctx) {
-		pemEncoded, err := r.client.Get(ctx, iter.Val()).Result()
+		c, err := r.publicCert(ctx, iter.Val())
 		if err != nil {
-			return nil, "", err
+			return err
 		}
-
-		decoded, _ := pem.Decode([]byte(pemEncoded))
-		if decoded == nil {
-			return nil, "", fmt.Errorf("is no valid pem block")
+		if c == nil || c.SerialNumber.Int64() != serial {
+			continue
 		}
 
-		c, err := x509.ParseCertificate(decoded.Bytes)
+		err = r.client.Del(ctx, iter.Val()).Err()
 		if err != nil {
-			return nil, "", err
+			return fmt.Errorf("unable to delete public key: %w", err)
 		}
 
-		key, err := jwk.Import(c.PublicKey)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to add public key: %w", err)
+		found = true
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if !found {
+		return errorutil.NotFound("no signing key with serial %d found", serial)
+	}
+
+	latest, err := r.latestPrivate(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	if latest != nil && latest.Serial == serial {
+		// the revoked key must not sign any further tokens, this also notifies about the change
+		_, _, err = r.setNewCert(ctx)
+		return err
+	}
+
+	err = r.client.Publish(ctx, channelChanges(), serial).Err()
+	if err != nil {
+		return fmt.Errorf("unable to publish key revocation: %w", err)
+	}
+
+	return nil
+}
+
+func (r *redisStore) Changes(ctx context.Context) (<-chan struct{}, error) {
+	var (
+		sub     = r.client.Subscribe(ctx, channelChanges())
+		changes = make(chan struct{}, 1)
+	)
+
+	// wait for the subscription to be established, otherwise changes right after this call could be missed
+	_, err := sub.Receive(ctx)
+	if err != nil {
+		_ = sub.Close()
+		return nil, fmt.Errorf("unable to subscribe to signing key changes: %w", err)
+	}
+
+	go func() {
+		defer close(changes)
+		defer func() {
+			_ = sub.Close()
+		}()
+
+		messages := sub.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				select {
+				case changes <- struct{}{}:
+				default:
+					// a notification is already pending
+				}
+			}
 		}
+	}()
 
-		err = set.AddKey(key)
+	return changes, nil
+}
+
+func (r *redisStore) publicCerts(ctx context.Context) ([]*x509.Certificate, error) {
+	var (
+		certs []*x509.Certificate
+		iter  = r.client.Scan(ctx, 0, matchPublic(), 0).Iterator()
+	)
+
+	for iter.Next(ctx) {
+		c, err := r.publicCert(ctx, iter.Val())
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
+		if c == nil {
+			continue
+		}
+
+		certs = append(certs, c)
 	}
 	if err := iter.Err(); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	res, err := json.MarshalIndent(set, "", "  ")
+	return certs, nil
+}
+
+// publicCert returns the certificate stored at the given key, nil is returned if the key has expired in the meantime
+func (r *redisStore) publicCert(ctx context.Context, key string) (*x509.Certificate, error) {
+	pemEncoded, err := r.client.Get(ctx, key).Result()
 	if err != nil {
-		return nil, "", fmt.Errorf("unable to marshal json: %w", err)
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
 	}
 
-	return set, string(res), nil
+	decoded, _ := pem.Decode([]byte(pemEncoded))
+	if decoded == nil {
+		return nil, fmt.Errorf("is no valid pem block")
+	}
+
+	return x509.ParseCertificate(decoded.Bytes)
 }
 
 func createRootCertificate(org string, from, to time.Time) (*x509.Certificate, *ecdsa.PrivateKey, []byte, error) {
@@ -206,7 +409,8 @@ func createRootCertificate(org string, from, to time.Time) (*x509.Certificate, *
 	}
 
 	tpl := &x509.Certificate{
-		SerialNumber: big.NewInt(time.Now().UnixMilli()),
+		// nanoseconds make sure keys rotated in quick succession can still be revoked individually
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
 		Subject: pkix.Name{
 			Organization: []string{org},
 		},
@@ -2,9 +2,11 @@ package certs_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/metal-stack/api/go/errorutil"
 	"github.com/metal-stack/metal-apiserver/pkg/certs"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
@@ -53,3 +55,90 @@ func Test_redisStore(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, jwk.Equal(firstKey, secondKey))
 }
+
+func Test_redisStore_RotateAndRevoke(t *testing.T) {
+	t.Parallel()
+	var (
+		ctx   = t.Context()
+		s     = miniredis.RunT(t)
+		c     = redis.NewClient(&redis.Options{Addr: s.Addr()})
+		store = certs.NewRedisStore(&certs.Config{
+			RedisClient: c,
+		})
+	)
+
+	changes, err := store.Changes(ctx)
+	require.NoError(t, err)
+
+	keys, err := store.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	firstPrivate, err := store.LatestPrivate(ctx)
+	require.NoError(t, err)
+	expectChange(t, changes)
+
+	first, err := store.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+	require.True(t, first[0].Latest)
+
+	second, err := store.Rotate(ctx)
+	require.NoError(t, err)
+	require.True(t, second.Latest)
+	expectChange(t, changes)
+
+	secondPrivate, err := store.LatestPrivate(ctx)
+	require.NoError(t, err)
+	require.False(t, firstPrivate.Equal(secondPrivate))
+
+	keys, err = store.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, first[0].Serial, keys[0].Serial)
+	require.False(t, keys[0].Latest)
+	require.Equal(t, second.Serial, keys[1].Serial)
+	require.True(t, keys[1].Latest)
+
+	// revoking an old key keeps the signing key
+	err = store.Revoke(ctx, first[0].Serial)
+	require.NoError(t, err)
+	expectChange(t, changes)
+
+	set, _, err := store.PublicKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, set.Len())
+
+	latestPrivate, err := store.LatestPrivate(ctx)
+	require.NoError(t, err)
+	require.True(t, secondPrivate.Equal(latestPrivate))
+
+	// revoking the signing key issues a new one
+	err = store.Revoke(ctx, second.Serial)
+	require.NoError(t, err)
+	expectChange(t, changes)
+
+	keys, err = store.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotEqual(t, second.Serial, keys[0].Serial)
+	require.True(t, keys[0].Latest)
+
+	latestPrivate, err = store.LatestPrivate(ctx)
+	require.NoError(t, err)
+	require.False(t, secondPrivate.Equal(latestPrivate))
+
+	err = store.Revoke(ctx, second.Serial)
+	require.Error(t, err)
+	require.True(t, errorutil.IsNotFound(err))
+}
+
+func expectChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a change notification")
+	}
+}
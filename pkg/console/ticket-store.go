@@ -0,0 +1,197 @@
+package console
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ticketSecretLength  = 32
+	ticketSecretLetters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-"
+
+	ticketprefix  = "consoleticket_by_secret_"
+	channelprefix = "consoleticket_partition_"
+)
+
+var (
+	// ErrTicketNotFound is returned if a ticket was already redeemed or expired
+	ErrTicketNotFound = errors.New("console ticket not found")
+	// ErrInvalidTicketSecret is returned if a secret can not be a ticket secret at all
+	ErrInvalidTicketSecret = errors.New("invalid console ticket secret")
+	// ErrTicketNotIssued is returned if a ticket is redeemed for another machine or user than it was issued for
+	ErrTicketNotIssued = errors.New("console ticket was not issued")
+
+	// redeemScript removes the ticket only if it was issued for the machine and user, such that a ticket can not
+	// be invalidated by someone else. It returns the ticket in any case, nil if it does not exist.
+	redeemScript = redis.NewScript(`
+local encoded = redis.call("GET", KEYS[1])
+if not encoded then
+	return false
+end
+local ticket = cjson.decode(encoded)
+if ticket.machine == ARGV[1] and ticket.user == ARGV[2] then
+	redis.call("DEL", KEYS[1])
+end
+return encoded
+`)
+)
+
+// Ticket grants a user a single console session to a machine until it expires.
+type Ticket struct {
+	Secret    string    `json:"secret"`
+	Machine   string    `json:"machine"`
+	Partition string    `json:"partition"`
+	Project   string    `json:"project"`
+	User      string    `json:"user"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TicketStore keeps console tickets until they are redeemed or expire.
+type TicketStore interface {
+	SetTicket(ctx context.Context, ticket *Ticket) error
+	// RedeemTicket returns the ticket of the secret and removes it, such that every ticket can only be used once.
+	// A ticket which was issued for another machine or user is neither returned nor removed.
+	RedeemTicket(ctx context.Context, secret, machine, user string) (*Ticket, error)
+}
+
+// Backend delivers issued tickets to the serial console gateway of the partition of the machine.
+type Backend interface {
+	Deliver(ctx context.Context, ticket *Ticket) error
+}
+
+type redisStore struct {
+	client *redis.Client
+}
+
+type redisBackend struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) TicketStore {
+	return &redisStore{
+		client: client,
+	}
+}
+
+// NewRedisBackend returns a backend which publishes tickets on a redis channel per partition,
+// the serial console gateways subscribe to the channel of their partition.
+func NewRedisBackend(client *redis.Client) Backend {
+	return &redisBackend{
+		client: client,
+	}
+}
+
+func ticketkey(secret string) string {
+	return ticketprefix + secret
+}
+
+// Channel returns the redis channel tickets for machines of the partition are published on.
+func Channel(partition string) string {
+	return channelprefix + partition
+}
+
+func (r *redisStore) SetTicket(ctx context.Context, t *Ticket) error {
+	if t.ExpiresAt.IsZero() {
+		return fmt.Errorf("console ticket needs to have an expiration")
+	}
+
+	if err := validateTicketSecret(t.Secret); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("unable to encode console ticket: %w", err)
+	}
+
+	return r.client.Set(ctx, ticketkey(t.Secret), string(encoded), time.Until(t.ExpiresAt)).Err()
+}
+
+func (r *redisStore) RedeemTicket(ctx context.Context, secret, machine, user string) (*Ticket, error) {
+	if err := validateTicketSecret(secret); err != nil {
+		return nil, err
+	}
+
+	encoded, err := redeemScript.Run(ctx, r.client, []string{ticketkey(secret)}, machine, user).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, err
+	}
+
+	var t Ticket
+	err = json.Unmarshal([]byte(encoded), &t)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.User != user {
+		return nil, fmt.Errorf("%w for user %s", ErrTicketNotIssued, user)
+	}
+	if t.Machine != machine {
+		return nil, fmt.Errorf("%w for machine %s", ErrTicketNotIssued, machine)
+	}
+
+	return &t, nil
+}
+
+func (r *redisBackend) Deliver(ctx context.Context, t *Ticket) error {
+	encoded, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("unable to encode console ticket: %w", err)
+	}
+
+	receivers, err := r.client.Publish(ctx, Channel(t.Partition), string(encoded)).Result()
+	if err != nil {
+		return err
+	}
+	if receivers == 0 {
+		return fmt.Errorf("no console gateway of partition %s received the ticket", t.Partition)
+	}
+
+	return nil
+}
+
+// GenerateTicketSecret returns a securely generated random string.
+// It will return an error if the system's secure random
+// number generator fails to function correctly, in which
+// case the caller should not continue.
+func GenerateTicketSecret() (string, error) {
+	ret := make([]byte, ticketSecretLength)
+	for i := range ticketSecretLength {
+
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(ticketSecretLetters))))
+		if err != nil {
+			return "", fmt.Errorf("unable to generate console ticket secret: %w", err)
+		}
+
+		ret[i] = ticketSecretLetters[num.Int64()]
+	}
+
+	return string(ret), nil
+}
+
+func validateTicketSecret(s string) error {
+	if len(s) != ticketSecretLength {
+		return fmt.Errorf("%w: unexpected length", ErrInvalidTicketSecret)
+	}
+
+	for _, letter := range s {
+		if !strings.ContainsRune(ticketSecretLetters, letter) {
+			return fmt.Errorf("%w: contains unexpected characters: %s", ErrInvalidTicketSecret, strconv.QuoteRune(letter))
+		}
+	}
+
+	return nil
+}
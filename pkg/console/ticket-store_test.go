@@ -0,0 +1,100 @@
+package console
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateTicketSecret(t *testing.T) {
+	secret, err := GenerateTicketSecret()
+	require.NoError(t, err)
+
+	require.NoError(t, validateTicketSecret(secret))
+	require.EqualError(t, validateTicketSecret("foo"), "invalid console ticket secret: unexpected length")
+	require.EqualError(t, validateTicketSecret("********************************"), "invalid console ticket secret: contains unexpected characters: '*'")
+	require.ErrorIs(t, validateTicketSecret("foo"), ErrInvalidTicketSecret)
+}
+
+func Test_RedeemTicket(t *testing.T) {
+	secret, err := GenerateTicketSecret()
+	require.NoError(t, err)
+
+	var (
+		now    = time.Now().UTC().Truncate(time.Second)
+		mr     = miniredis.RunT(t)
+		client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		store  = NewRedisStore(client)
+		ctx    = t.Context()
+		ticket = &Ticket{
+			Secret:    secret,
+			Machine:   "c0ffee00-0000-0000-0000-000000000001",
+			Partition: "partition-a",
+			Project:   "project-a",
+			User:      "user-a",
+			IssuedAt:  now,
+			ExpiresAt: now.Add(5 * time.Minute),
+		}
+	)
+
+	err = store.SetTicket(ctx, &Ticket{Secret: secret})
+	require.EqualError(t, err, "console ticket needs to have an expiration")
+
+	err = store.SetTicket(ctx, ticket)
+	require.NoError(t, err)
+
+	// redeeming a ticket for another machine or user must not invalidate it
+	_, err = store.RedeemTicket(ctx, secret, "c0ffee00-0000-0000-0000-000000000002", ticket.User)
+	require.EqualError(t, err, "console ticket was not issued for machine c0ffee00-0000-0000-0000-000000000002")
+	_, err = store.RedeemTicket(ctx, secret, ticket.Machine, "user-b")
+	require.ErrorIs(t, err, ErrTicketNotIssued)
+
+	got, err := store.RedeemTicket(ctx, secret, ticket.Machine, ticket.User)
+	require.NoError(t, err)
+	if diff := cmp.Diff(ticket, got); diff != "" {
+		t.Errorf("diff (+got -want):\n %s", diff)
+	}
+
+	// a ticket can only be redeemed once
+	_, err = store.RedeemTicket(ctx, secret, ticket.Machine, ticket.User)
+	require.ErrorIs(t, err, ErrTicketNotFound)
+
+	err = store.SetTicket(ctx, ticket)
+	require.NoError(t, err)
+
+	mr.FastForward(6 * time.Minute)
+
+	_, err = store.RedeemTicket(ctx, secret, ticket.Machine, ticket.User)
+	require.ErrorIs(t, err, ErrTicketNotFound)
+}
+
+func Test_Deliver(t *testing.T) {
+	var (
+		mr      = miniredis.RunT(t)
+		client  = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		backend = NewRedisBackend(client)
+		ctx     = t.Context()
+		ticket  = &Ticket{Machine: "c0ffee00-0000-0000-0000-000000000001", Partition: "partition-a"}
+	)
+
+	err := backend.Deliver(ctx, ticket)
+	require.EqualError(t, err, "no console gateway of partition partition-a received the ticket")
+
+	sub := client.Subscribe(ctx, Channel("partition-a"))
+	defer func() {
+		_ = sub.Close()
+	}()
+	_, err = sub.Receive(ctx)
+	require.NoError(t, err)
+
+	err = backend.Deliver(ctx, ticket)
+	require.NoError(t, err)
+
+	msg, err := sub.ReceiveMessage(ctx)
+	require.NoError(t, err)
+	require.Contains(t, msg.Payload, `"machine":"c0ffee00-0000-0000-0000-000000000001"`)
+}
@@ -29,6 +29,11 @@ type (
 		image               *storage[*metal.Image]
 		sw                  *storage[*metal.Switch]
 		switchStatus        *storage[*metal.SwitchStatus]
+		capacitySnapshot    *storage[*metal.PartitionCapacitySnapshot]
+		machineTimeline     *storage[*metal.MachineTimelineEntry]
+		hardwareSnapshot    *storage[*metal.MachineHardwareSnapshot]
+		firmwareCatalog     *storage[*metal.FirmwareCatalog]
+		remediationPolicy   *storage[*metal.MachineRemediationPolicy]
 
 		asnPool *integerPool
 		vrfPool *integerPool
@@ -36,6 +41,8 @@ type (
 		sharedMutex *sharedMutex
 
 		tableNames []string
+		// tableIndexes contains the secondary indexes of a table by table name
+		tableIndexes map[string][]string
 	}
 )
 
@@ -55,6 +62,7 @@ func New(log *slog.Logger, opts r.ConnectOpts, dsOpts ...dataStoreOption) (*data
 		log:           log,
 		queryExecutor: session,
 		dbname:        opts.Database,
+		tableIndexes:  map[string][]string{},
 	}
 
 	ds.ip = newStorage[*metal.IP](ds, "ip")
@@ -69,6 +77,11 @@ func New(log *slog.Logger, opts r.ConnectOpts, dsOpts ...dataStoreOption) (*data
 	ds.event = newStorage[*metal.ProvisioningEventContainer](ds, "event")
 	ds.sw = newStorage[*metal.Switch](ds, "switch")
 	ds.switchStatus = newStorage[*metal.SwitchStatus](ds, "switchstatus")
+	ds.capacitySnapshot = newStorage[*metal.PartitionCapacitySnapshot](ds, "partitioncapacitysnapshot")
+	ds.machineTimeline = newStorage[*metal.MachineTimelineEntry](ds, "machinetimeline", "machine", "time")
+	ds.hardwareSnapshot = newStorage[*metal.MachineHardwareSnapshot](ds, "machinehardwaresnapshot")
+	ds.firmwareCatalog = newStorage[*metal.FirmwareCatalog](ds, "firmwarecatalog")
+	ds.remediationPolicy = newStorage[*metal.MachineRemediationPolicy](ds, "machineremediationpolicy")
 
 	var (
 		vrfMin  = uint(1)
@@ -176,6 +189,26 @@ func (ds *datastore) Event() Storage[*metal.ProvisioningEventContainer] {
 	return ds.event
 }
 
+func (ds *datastore) PartitionCapacitySnapshot() Storage[*metal.PartitionCapacitySnapshot] {
+	return ds.capacitySnapshot
+}
+
+func (ds *datastore) MachineTimeline() Storage[*metal.MachineTimelineEntry] {
+	return ds.machineTimeline
+}
+
+func (ds *datastore) MachineHardwareSnapshot() Storage[*metal.MachineHardwareSnapshot] {
+	return ds.hardwareSnapshot
+}
+
+func (ds *datastore) FirmwareCatalog() Storage[*metal.FirmwareCatalog] {
+	return ds.firmwareCatalog
+}
+
+func (ds *datastore) MachineRemediationPolicy() Storage[*metal.MachineRemediationPolicy] {
+	return ds.remediationPolicy
+}
+
 func (ds *datastore) AsnPool() *integerPool {
 	return ds.asnPool
 }
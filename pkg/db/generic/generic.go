@@ -33,6 +33,7 @@ type (
 		Update(ctx context.Context, e E) error
 		Upsert(ctx context.Context, e E) error
 		Delete(ctx context.Context, e E) error
+		DeleteAll(ctx context.Context, queries ...EntityQuery) (int, error)
 		Get(ctx context.Context, id string) (E, error)
 		Find(ctx context.Context, queries ...EntityQuery) (E, error)
 		List(ctx context.Context, queries ...EntityQuery) ([]E, error)
@@ -51,6 +52,11 @@ type (
 		Switch() Storage[*metal.Switch]
 		SwitchStatus() Storage[*metal.SwitchStatus]
 		Event() Storage[*metal.ProvisioningEventContainer]
+		PartitionCapacitySnapshot() Storage[*metal.PartitionCapacitySnapshot]
+		MachineTimeline() Storage[*metal.MachineTimelineEntry]
+		MachineHardwareSnapshot() Storage[*metal.MachineHardwareSnapshot]
+		FirmwareCatalog() Storage[*metal.FirmwareCatalog]
+		MachineRemediationPolicy() Storage[*metal.MachineRemediationPolicy]
 
 		// sizeimageConstraint Storage[*metal.SizeImageConstraint]
 
@@ -107,6 +107,16 @@ func Initialize(ctx context.Context, log *slog.Logger, opts r.ConnectOpts, dsOpt
 		}
 	}()
 
+	ds.log.Info("initializing indexes")
+
+	for tableName, indexes := range ds.tableIndexes {
+		for _, index := range indexes {
+			if err := ds.createIndex(ctx, tableName, index); err != nil {
+				return fmt.Errorf("cannot create index %s on %s table: %w", index, tableName, err)
+			}
+		}
+	}
+
 	ds.log.Info("initializing pools")
 
 	if err := ds.asnPool.initialize(); err != nil {
@@ -133,3 +143,23 @@ func (ds *datastore) createTable(ctx context.Context, tableName string) error {
 
 	return nil
 }
+
+func (ds *datastore) createIndex(ctx context.Context, tableName, index string) error {
+	ds.log.Info("init index", "db", ds.dbname, "table", tableName, "index", index)
+
+	table := r.DB(ds.dbname).Table(tableName)
+
+	err := table.IndexList().Contains(index).Do(func(row r.Term) r.Term {
+		return r.Branch(row, nil, table.IndexCreate(index))
+	}).Exec(ds.queryExecutor, r.ExecOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("cannot create index %s: %w", index, err)
+	}
+
+	err = table.IndexWait(index).Exec(ds.queryExecutor, r.ExecOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("unable to wait for index %s: %w", index, err)
+	}
+
+	return nil
+}
@@ -18,9 +18,13 @@ type storage[E Entity] struct {
 	tableName string
 }
 
-// newStorage creates a new Storage which uses the given database abstraction.
-func newStorage[E Entity](re *datastore, tableName string) *storage[E] {
+// newStorage creates a new Storage which uses the given database abstraction,
+// the given secondary indexes are created on the table during initialization.
+func newStorage[E Entity](re *datastore, tableName string, indexes ...string) *storage[E] {
 	re.tableNames = append(re.tableNames, tableName)
+	if len(indexes) > 0 {
+		re.tableIndexes[tableName] = indexes
+	}
 	return &storage[E]{
 		r:         re,
 		table:     r.DB(re.dbname).Table(tableName),
@@ -78,6 +82,22 @@ func (s *storage[E]) Delete(ctx context.Context, e E) error {
 	return nil
 }
 
+// DeleteAll deletes all entities matched by the given queries in the database and returns the number of deleted entities.
+func (s *storage[E]) DeleteAll(ctx context.Context, queries ...EntityQuery) (int, error) {
+	query := s.table
+	for _, q := range queries {
+		query = q(query)
+	}
+	s.r.log.Debug("delete all", "table", s.tableName, "query", query.String())
+
+	res, err := query.Delete().RunWrite(s.r.queryExecutor, r.RunOpts{Context: ctx})
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete %v from database: %w", s.tableName, err)
+	}
+
+	return res.Deleted, nil
+}
+
 // Find attempts to find a single entity from the given set of queries.
 //
 // if either none or more than one entities were found by the query, an error gets returned.
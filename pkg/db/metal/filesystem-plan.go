@@ -0,0 +1,311 @@
+package metal
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type (
+	// FilesystemPlan is the resolved result of a FilesystemLayout applied to the hardware of a machine.
+	// All sizes are in mebibytes (MiB).
+	FilesystemPlan struct {
+		Layout         string
+		Disks          []DiskPlan
+		Raid           []RaidPlan
+		VolumeGroups   []VolumeGroupPlan
+		LogicalVolumes []LogicalVolumePlan
+		Filesystems    []FilesystemPlanEntry
+		// UnusedDisks are disks of the machine which are not referenced by the layout
+		UnusedDisks []string
+		// Problems contains the reasons why the layout does not fit the hardware, the layout fits if it is empty
+		Problems []string
+	}
+
+	DiskPlan struct {
+		Device     string
+		Size       uint64
+		Partitions []PartitionPlan
+		// Unallocated is the space which is left on the disk after all partitions were created
+		Unallocated uint64
+	}
+
+	PartitionPlan struct {
+		Number  uint8
+		Device  string
+		Label   string
+		Size    uint64
+		GPTType GPTType
+	}
+
+	RaidPlan struct {
+		ArrayName string
+		Level     RaidLevel
+		Members   []string
+		Spares    []string
+		Size      uint64
+	}
+
+	VolumeGroupPlan struct {
+		Name    string
+		Devices []string
+		Size    uint64
+		// Free is the space which is left in the volume group after all logical volumes were created
+		Free uint64
+	}
+
+	LogicalVolumePlan struct {
+		Name        string
+		VolumeGroup string
+		Device      string
+		LVMType     LVMType
+		Size        uint64
+	}
+
+	FilesystemPlanEntry struct {
+		Path   string
+		Device string
+		Format Format
+		Size   uint64
+	}
+)
+
+// Fits returns true if the layout can be applied to the hardware.
+func (p *FilesystemPlan) Fits() bool {
+	return len(p.Problems) == 0
+}
+
+func (p *FilesystemPlan) problem(format string, args ...any) {
+	p.Problems = append(p.Problems, fmt.Sprintf(format, args...))
+}
+
+// Plan resolves the FilesystemLayout against the given hardware, it computes the sizes of all partitions, raid arrays,
+// volume groups and logical volumes and collects every reason why the layout does not fit instead of stopping at the first one.
+// The layout itself is expected to be valid.
+func (fl *FilesystemLayout) Plan(hardware MachineHardware) *FilesystemPlan {
+	plan := &FilesystemPlan{
+		Layout: fl.ID,
+	}
+
+	var (
+		existing = map[string]uint64{}
+		// devices contains the sizes of all block devices which are provided by the layout
+		devices = map[string]uint64{}
+	)
+
+	for _, disk := range hardware.Disks {
+		name := disk.Name
+		if !strings.HasPrefix(name, "/dev/") {
+			name = fmt.Sprintf("/dev/%s", disk.Name)
+		}
+		existing[name] = disk.Size / (1024 * 1024)
+	}
+
+	for _, disk := range fl.Disks {
+		size, ok := existing[disk.Device]
+		if !ok {
+			plan.problem("device:%s does not exist on given hardware", disk.Device)
+			continue
+		}
+		devices[disk.Device] = size
+
+		plan.Disks = append(plan.Disks, planDisk(plan, disk, size, devices))
+	}
+
+	for name := range existing {
+		if !slices.ContainsFunc(fl.Disks, func(d Disk) bool { return d.Device == name }) {
+			plan.UnusedDisks = append(plan.UnusedDisks, name)
+		}
+	}
+	sort.Strings(plan.UnusedDisks)
+
+	for _, raid := range fl.Raid {
+		plan.Raid = append(plan.Raid, planRaid(plan, raid, devices))
+	}
+
+	for _, vg := range fl.VolumeGroups {
+		vp := VolumeGroupPlan{
+			Name:    vg.Name,
+			Devices: vg.Devices,
+		}
+		for _, device := range vg.Devices {
+			size, ok := devices[device]
+			if !ok {
+				plan.problem("device:%s of vg:%s is not available", device, vg.Name)
+				continue
+			}
+			vp.Size += size
+		}
+		vp.Free = vp.Size
+
+		plan.VolumeGroups = append(plan.VolumeGroups, vp)
+	}
+
+	for _, lv := range fl.LogicalVolumes {
+		idx := slices.IndexFunc(plan.VolumeGroups, func(vg VolumeGroupPlan) bool { return vg.Name == lv.VolumeGroup })
+		if idx < 0 {
+			plan.problem("volumegroup:%s not configured for lv:%s", lv.VolumeGroup, lv.Name)
+			continue
+		}
+		vg := &plan.VolumeGroups[idx]
+
+		// a raid1 logical volume is mirrored, it consumes its size twice
+		copies := uint64(1)
+		if lv.LVMType == LVMTypeRaid1 {
+			copies = 2
+		}
+
+		size := lv.Size
+		if size == 0 {
+			size = vg.Free / copies
+		}
+		switch {
+		case size*copies > vg.Free:
+			plan.problem("lv:%s in vg:%s requires:%dMiB, remaining:%dMiB", lv.Name, lv.VolumeGroup, size*copies, vg.Free)
+			size = vg.Free / copies
+		case size == 0:
+			plan.problem("lv:%s in vg:%s has no space left", lv.Name, lv.VolumeGroup)
+		}
+		vg.Free -= size * copies
+
+		device := path.Join("/dev/", lv.VolumeGroup, lv.Name)
+		devices[device] = size
+
+		plan.LogicalVolumes = append(plan.LogicalVolumes, LogicalVolumePlan{
+			Name:        lv.Name,
+			VolumeGroup: lv.VolumeGroup,
+			Device:      device,
+			LVMType:     lv.LVMType,
+			Size:        size,
+		})
+	}
+
+	for _, fs := range fl.Filesystems {
+		entry := FilesystemPlanEntry{
+			Device: fs.Device,
+			Format: fs.Format,
+		}
+		if fs.Path != nil {
+			entry.Path = *fs.Path
+		}
+
+		if fs.Format != TMPFS {
+			size, ok := devices[fs.Device]
+			if !ok {
+				plan.problem("device:%s for filesystem:%s is not available", fs.Device, entry.Path)
+			}
+			entry.Size = size
+		}
+
+		plan.Filesystems = append(plan.Filesystems, entry)
+	}
+
+	return plan
+}
+
+func planDisk(plan *FilesystemPlan, disk Disk, size uint64, devices map[string]uint64) DiskPlan {
+	var (
+		dp = DiskPlan{
+			Device: disk.Device,
+			Size:   size,
+		}
+		required   uint64
+		partitions = slices.Clone(disk.Partitions)
+	)
+
+	slices.SortFunc(partitions, func(a, b DiskPartition) int {
+		return int(a.Number) - int(b.Number)
+	})
+
+	for _, p := range partitions {
+		required += p.Size
+	}
+	if required > size {
+		plan.problem("device:%s is not big enough required:%dMiB, existing:%dMiB", disk.Device, required, size)
+	}
+
+	remaining := size - min(required, size)
+
+	for _, p := range partitions {
+		partitionPrefix := ""
+		if strings.HasPrefix(disk.Device, "/dev/nvme") {
+			partitionPrefix = "p"
+		}
+
+		pp := PartitionPlan{
+			Number: p.Number,
+			Device: fmt.Sprintf("%s%s%d", disk.Device, partitionPrefix, p.Number),
+			Size:   p.Size,
+		}
+		if p.Label != nil {
+			pp.Label = *p.Label
+		}
+		if p.GPTType != nil {
+			pp.GPTType = *p.GPTType
+		}
+
+		if p.Size == 0 {
+			// the variable sized partition takes the rest of the device
+			pp.Size = remaining
+			remaining = 0
+			if pp.Size == 0 {
+				plan.problem("device:%s has no space left for variable sized partition:%d", disk.Device, p.Number)
+			}
+		}
+
+		devices[pp.Device] = pp.Size
+		dp.Partitions = append(dp.Partitions, pp)
+	}
+
+	dp.Unallocated = remaining
+
+	return dp
+}
+
+func planRaid(plan *FilesystemPlan, raid Raid, devices map[string]uint64) RaidPlan {
+	rp := RaidPlan{
+		ArrayName: raid.ArrayName,
+		Level:     raid.Level,
+	}
+
+	if raid.Spares >= len(raid.Devices) {
+		plan.problem("raid:%s has %d spares but only %d devices", raid.ArrayName, raid.Spares, len(raid.Devices))
+		return rp
+	}
+
+	active := len(raid.Devices) - raid.Spares
+	rp.Members = raid.Devices[:active]
+	rp.Spares = raid.Devices[active:]
+
+	var (
+		smallest uint64
+		found    bool
+	)
+	for _, device := range raid.Devices {
+		size, ok := devices[device]
+		if !ok {
+			plan.problem("device:%s of raid:%s is not available", device, raid.ArrayName)
+			continue
+		}
+		if !found || size < smallest {
+			smallest = size
+			found = true
+		}
+	}
+
+	switch raid.Level {
+	case RaidLevel0:
+		rp.Size = smallest * uint64(active)
+	case RaidLevel1:
+		if active < 2 {
+			plan.problem("raid:%s with level:%s requires at least two active devices", raid.ArrayName, raid.Level)
+		}
+		rp.Size = smallest
+	}
+
+	devices[raid.ArrayName] = rp.Size
+
+	return rp
+}
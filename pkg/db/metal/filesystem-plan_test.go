@@ -0,0 +1,165 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilesystemLayout_Plan(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+
+	var (
+		efi   = "efi"
+		boot  = "/boot/efi"
+		root  = "/"
+		varfs = "/var"
+		tmp   = "/tmp"
+
+		layout = &FilesystemLayout{
+			Base: Base{ID: "raid-lvm"},
+			Disks: []Disk{
+				{Device: "/dev/sda", Partitions: []DiskPartition{{Number: 1, Label: &efi, Size: 500, GPTType: new(GPTBoot)}, {Number: 2, GPTType: new(GPTLinuxRaid)}}},
+				{Device: "/dev/sdb", Partitions: []DiskPartition{{Number: 1, Size: 500, GPTType: new(GPTBoot)}, {Number: 2, GPTType: new(GPTLinuxRaid)}}},
+			},
+			Raid: []Raid{
+				{ArrayName: "/dev/md1", Devices: []string{"/dev/sda2", "/dev/sdb2"}, Level: RaidLevel1},
+			},
+			VolumeGroups: []VolumeGroup{
+				{Name: "vg00", Devices: []string{"/dev/md1"}},
+			},
+			LogicalVolumes: LogicalVolumes{
+				{Name: "root", VolumeGroup: "vg00", Size: 10240, LVMType: LVMTypeLinear},
+				{Name: "var", VolumeGroup: "vg00", LVMType: LVMTypeLinear},
+			},
+			Filesystems: []Filesystem{
+				{Path: &boot, Device: "/dev/sda1", Format: VFAT},
+				{Path: &root, Device: "/dev/vg00/root", Format: EXT4},
+				{Path: &varfs, Device: "/dev/vg00/var", Format: EXT4},
+				{Path: &tmp, Device: "tmpfs", Format: TMPFS},
+			},
+		}
+	)
+
+	tests := []struct {
+		name     string
+		hardware MachineHardware
+		want     *FilesystemPlan
+	}{
+		{
+			name: "layout fits",
+			hardware: MachineHardware{Disks: []BlockDevice{
+				{Name: "sda", Size: 100 * gib},
+				{Name: "sdb", Size: 100 * gib},
+				{Name: "nvme0n1", Size: 100 * gib},
+			}},
+			want: &FilesystemPlan{
+				Layout: "raid-lvm",
+				Disks: []DiskPlan{
+					{Device: "/dev/sda", Size: 102400, Partitions: []PartitionPlan{
+						{Number: 1, Device: "/dev/sda1", Label: "efi", Size: 500, GPTType: GPTBoot},
+						{Number: 2, Device: "/dev/sda2", Size: 101900, GPTType: GPTLinuxRaid},
+					}},
+					{Device: "/dev/sdb", Size: 102400, Partitions: []PartitionPlan{
+						{Number: 1, Device: "/dev/sdb1", Size: 500, GPTType: GPTBoot},
+						{Number: 2, Device: "/dev/sdb2", Size: 101900, GPTType: GPTLinuxRaid},
+					}},
+				},
+				Raid: []RaidPlan{
+					{ArrayName: "/dev/md1", Level: RaidLevel1, Members: []string{"/dev/sda2", "/dev/sdb2"}, Spares: []string{}, Size: 101900},
+				},
+				VolumeGroups: []VolumeGroupPlan{
+					{Name: "vg00", Devices: []string{"/dev/md1"}, Size: 101900},
+				},
+				LogicalVolumes: []LogicalVolumePlan{
+					{Name: "root", VolumeGroup: "vg00", Device: "/dev/vg00/root", LVMType: LVMTypeLinear, Size: 10240},
+					{Name: "var", VolumeGroup: "vg00", Device: "/dev/vg00/var", LVMType: LVMTypeLinear, Size: 91660},
+				},
+				Filesystems: []FilesystemPlanEntry{
+					{Path: "/boot/efi", Device: "/dev/sda1", Format: VFAT, Size: 500},
+					{Path: "/", Device: "/dev/vg00/root", Format: EXT4, Size: 10240},
+					{Path: "/var", Device: "/dev/vg00/var", Format: EXT4, Size: 91660},
+					{Path: "/tmp", Device: "tmpfs", Format: TMPFS},
+				},
+				UnusedDisks: []string{"/dev/nvme0n1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := layout.Plan(tt.hardware)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FilesystemLayout.Plan() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilesystemLayout_PlanProblems(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+
+	layout := &FilesystemLayout{
+		Disks: []Disk{
+			{Device: "/dev/sda", Partitions: []DiskPartition{{Number: 1, Size: 500}, {Number: 2}}},
+			{Device: "/dev/sdb", Partitions: []DiskPartition{{Number: 1, Size: 500}, {Number: 2}}},
+		},
+		Raid: []Raid{
+			{ArrayName: "/dev/md1", Devices: []string{"/dev/sda2", "/dev/sdb2"}, Level: RaidLevel1},
+		},
+		VolumeGroups: []VolumeGroup{
+			{Name: "vg00", Devices: []string{"/dev/md1"}},
+		},
+		LogicalVolumes: LogicalVolumes{
+			{Name: "root", VolumeGroup: "vg00", Size: 10240, LVMType: LVMTypeLinear},
+			{Name: "var", VolumeGroup: "vg00", LVMType: LVMTypeLinear},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		layout   *FilesystemLayout
+		hardware MachineHardware
+		want     []string
+	}{
+		{
+			name:     "missing disk",
+			layout:   layout,
+			hardware: MachineHardware{Disks: []BlockDevice{{Name: "/dev/sda", Size: 100 * gib}}},
+			want: []string{
+				"device:/dev/sdb does not exist on given hardware",
+				"device:/dev/sdb2 of raid:/dev/md1 is not available",
+			},
+		},
+		{
+			name:     "disks too small for the logical volumes",
+			layout:   layout,
+			hardware: MachineHardware{Disks: []BlockDevice{{Name: "/dev/sda", Size: 8 * gib}, {Name: "/dev/sdb", Size: 8 * gib}}},
+			want: []string{
+				"lv:root in vg:vg00 requires:10240MiB, remaining:7692MiB",
+				"lv:var in vg:vg00 has no space left",
+			},
+		},
+		{
+			name:     "disk too small for the partitions",
+			layout:   layout,
+			hardware: MachineHardware{Disks: []BlockDevice{{Name: "/dev/sda", Size: 100 * gib}, {Name: "/dev/sdb", Size: 400 * 1024 * 1024}}},
+			want: []string{
+				"device:/dev/sdb is not big enough required:500MiB, existing:400MiB",
+				"device:/dev/sdb has no space left for variable sized partition:2",
+				"lv:root in vg:vg00 requires:10240MiB, remaining:0MiB",
+				"lv:var in vg:vg00 has no space left",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.layout.Plan(tt.hardware)
+			if diff := cmp.Diff(tt.want, got.Problems); diff != "" {
+				t.Errorf("FilesystemLayout.Plan() problems diff (-want +got):\n%s", diff)
+			}
+			if got.Fits() {
+				t.Errorf("FilesystemLayout.Plan() fits, but expected problems")
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package metal
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"sort"
@@ -497,39 +498,16 @@ func (fls FilesystemLayouts) From(size, image string) (*FilesystemLayout, error)
 	return nil, fmt.Errorf("could not find a matching filesystemLayout for size:%s and image:%s", size, image)
 }
 
-// Matches the specific FilesystemLayout against the selected Hardware
+// Matches the specific FilesystemLayout against the selected Hardware, it fails for every reason the Plan of the layout does not fit
 func (fl *FilesystemLayout) Matches(hardware MachineHardware) error {
-	requiredDevices := make(map[string]uint64)
-	existingDevices := make(map[string]uint64)
+	plan := fl.Plan(hardware)
 
-	for _, disk := range fl.Disks {
-		var requiredSize uint64
-		for _, partition := range disk.Partitions {
-			requiredSize += partition.Size
-		}
-		requiredDevices[disk.Device] = requiredSize
-	}
-
-	for _, disk := range hardware.Disks {
-		diskName := disk.Name
-		if !strings.HasPrefix(diskName, "/dev/") {
-			diskName = fmt.Sprintf("/dev/%s", disk.Name)
-		}
-		// convert bytes to mebibytes
-		size := disk.Size / (1024 * 1024)
-		existingDevices[diskName] = size
+	var errs []error
+	for _, problem := range plan.Problems {
+		errs = append(errs, errors.New(problem))
 	}
 
-	for requiredDevice, requiredSize := range requiredDevices {
-		existingSize, ok := existingDevices[requiredDevice]
-		if !ok {
-			return fmt.Errorf("device:%s does not exist on given hardware", requiredDevice)
-		}
-		if existingSize < requiredSize {
-			return fmt.Errorf("device:%s is not big enough required:%dMiB, existing:%dMiB", requiredDevice, requiredSize, existingSize)
-		}
-	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func supportedFormats() string {
@@ -335,6 +335,21 @@ func TestFilesystemLayout_Matches(t *testing.T) {
 			wantErr:   true,
 			errString: "device:/dev/sdb is not big enough required:200MiB, existing:95MiB",
 		},
+		{
+			name: "no match raid without active devices",
+			fields: fields{
+				Disks: []Disk{
+					{Device: "/dev/sda", Partitions: []DiskPartition{{Number: 1, Size: 100}}},
+					{Device: "/dev/sdb", Partitions: []DiskPartition{{Number: 1, Size: 100}}}},
+				Raid: []Raid{{ArrayName: "/dev/md1", Devices: []string{"/dev/sda1", "/dev/sdb1"}, Level: RaidLevel1, Spares: 2}},
+			},
+			args: args{hardware: MachineHardware{Disks: []BlockDevice{
+				{Name: "/dev/sda", Size: 300000000},
+				{Name: "/dev/sdb", Size: 300000000},
+			}}},
+			wantErr:   true,
+			errString: "raid:/dev/md1 has 2 spares but only 2 devices",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
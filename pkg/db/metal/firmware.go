@@ -0,0 +1,186 @@
+package metal
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FirmwareKind is the kind of firmware which is updated through the bmc.
+type FirmwareKind string
+
+const (
+	FirmwareKindBIOS FirmwareKind = "bios"
+	FirmwareKindBMC  FirmwareKind = "bmc"
+)
+
+var firmwareKinds = []FirmwareKind{FirmwareKindBIOS, FirmwareKindBMC}
+
+type (
+	// FirmwareCatalog contains the available firmware revisions of a kind for the boards of a vendor
+	// and the revision machines should run. The ID of a catalog is <kind>:<vendor>:<board>.
+	// Machines are matched by the board manufacturer and board part number of their fru.
+	FirmwareCatalog struct {
+		Base
+		Kind   FirmwareKind `rethinkdb:"kind"`
+		Vendor string       `rethinkdb:"vendor"`
+		Board  string       `rethinkdb:"board"`
+		// Target is the revision machines should run, no machine is outdated if empty
+		Target    string             `rethinkdb:"target"`
+		Revisions []FirmwareRevision `rethinkdb:"revisions"`
+	}
+
+	FirmwareRevision struct {
+		Revision string `rethinkdb:"revision"`
+		// URL is the location the bmc downloads the firmware from
+		URL string `rethinkdb:"url"`
+	}
+
+	// FirmwareCompliance is the firmware revision a machine runs compared to the target revision of its catalog.
+	FirmwareCompliance struct {
+		Machine   string
+		Partition string
+		Kind      FirmwareKind
+		Vendor    string
+		Board     string
+		Current   string
+		Target    string
+		// Outdated is true if the current revision is below the target or unknown
+		Outdated bool
+	}
+)
+
+// FirmwareCatalogID returns the id of the firmware catalog of the given kind, vendor and board.
+func FirmwareCatalogID(kind FirmwareKind, vendor, board string) string {
+	return string(kind) + ":" + vendor + ":" + board
+}
+
+// Validate checks the kind, vendor and board of the catalog, its revisions and that the target is one of them.
+func (c *FirmwareCatalog) Validate() error {
+	if !slices.Contains(firmwareKinds, c.Kind) {
+		return fmt.Errorf("invalid kind:%q, must be one of %v", c.Kind, firmwareKinds)
+	}
+	if c.Vendor == "" {
+		return fmt.Errorf("vendor must not be empty")
+	}
+	if c.Board == "" {
+		return fmt.Errorf("board must not be empty")
+	}
+
+	var revisions []string
+	for _, r := range c.Revisions {
+		if r.Revision == "" {
+			return fmt.Errorf("revision must not be empty")
+		}
+		if r.URL == "" {
+			return fmt.Errorf("url of revision %q must not be empty", r.Revision)
+		}
+		if slices.Contains(revisions, r.Revision) {
+			return fmt.Errorf("revision %q is contained more than once", r.Revision)
+		}
+		revisions = append(revisions, r.Revision)
+	}
+
+	if c.Target != "" && !slices.Contains(revisions, c.Target) {
+		return fmt.Errorf("target %q must be one of the revisions %v", c.Target, revisions)
+	}
+
+	return nil
+}
+
+// Matches returns true if the catalog applies to the board of the machine.
+func (c *FirmwareCatalog) Matches(m *Machine) bool {
+	return m.IPMI.Fru.BoardMfg == c.Vendor && m.IPMI.Fru.BoardPartNumber == c.Board
+}
+
+// FirmwareRevisionOf returns the firmware revision of the given kind the machine reported.
+func FirmwareRevisionOf(m *Machine, kind FirmwareKind) string {
+	switch kind {
+	case FirmwareKindBIOS:
+		return m.BIOS.Version
+	case FirmwareKindBMC:
+		return m.IPMI.BMCVersion
+	default:
+		return ""
+	}
+}
+
+// Compliance compares the firmware revision of the machine with the target revision of the catalog.
+func (c *FirmwareCatalog) Compliance(m *Machine) *FirmwareCompliance {
+	current := FirmwareRevisionOf(m, c.Kind)
+
+	return &FirmwareCompliance{
+		Machine:   m.ID,
+		Partition: m.PartitionID,
+		Kind:      c.Kind,
+		Vendor:    c.Vendor,
+		Board:     c.Board,
+		Current:   current,
+		Target:    c.Target,
+		Outdated:  c.Target != "" && (current == "" || CompareFirmwareRevisions(current, c.Target) < 0),
+	}
+}
+
+// CompareFirmwareRevisions compares two firmware revisions, vendors do not follow semantic versioning.
+// The revisions are split into numeric and non numeric parts, numeric parts are compared by their value,
+// all others lexically, such that 1.10a is newer than 1.9b and 3.3a is newer than 3.3.
+func CompareFirmwareRevisions(a, b string) int {
+	as, bs := firmwareRevisionParts(a), firmwareRevisionParts(b)
+
+	for i := range min(len(as), len(bs)) {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+
+		var c int
+		if aErr == nil && bErr == nil {
+			c = cmp.Compare(an, bn)
+		} else {
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(as), len(bs))
+}
+
+func firmwareRevisionParts(revision string) []string {
+	var (
+		parts   []string
+		current strings.Builder
+		digits  bool
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(revision) {
+		switch {
+		case unicode.IsDigit(r):
+			if !digits {
+				flush()
+			}
+			digits = true
+			current.WriteRune(r)
+		case unicode.IsLetter(r):
+			if digits {
+				flush()
+			}
+			digits = false
+			current.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return parts
+}
@@ -0,0 +1,118 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareFirmwareRevisions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.73.07", b: "1.73.07", want: 0},
+		{a: "1.9", b: "1.10", want: -1},
+		{a: "3.3a", b: "3.3", want: 1},
+		{a: "3.3a", b: "3.3b", want: -1},
+		{a: "1.10a", b: "1.9b", want: 1},
+		{a: "v2.1", b: "V2.1", want: 0},
+		{a: "01.73.07", b: "1.73.7", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"-"+tt.b, func(t *testing.T) {
+			require.Equal(t, tt.want, CompareFirmwareRevisions(tt.a, tt.b))
+		})
+	}
+}
+
+func TestFirmwareCatalog_Compliance(t *testing.T) {
+	catalog := &FirmwareCatalog{
+		Kind:   FirmwareKindBIOS,
+		Vendor: "Supermicro",
+		Board:  "X11DPT-B",
+		Target: "3.3",
+		Revisions: []FirmwareRevision{
+			{Revision: "3.2", URL: "http://firmware/x11dpt-b-3.2.zip"},
+			{Revision: "3.3", URL: "http://firmware/x11dpt-b-3.3.zip"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		machine      *Machine
+		wantCurrent  string
+		wantOutdated bool
+	}{
+		{
+			name:         "outdated",
+			machine:      &Machine{Base: Base{ID: "m1"}, BIOS: BIOS{Version: "3.2"}},
+			wantCurrent:  "3.2",
+			wantOutdated: true,
+		},
+		{
+			name:         "up to date",
+			machine:      &Machine{Base: Base{ID: "m2"}, BIOS: BIOS{Version: "3.3"}},
+			wantCurrent:  "3.3",
+			wantOutdated: false,
+		},
+		{
+			name:         "newer than target",
+			machine:      &Machine{Base: Base{ID: "m3"}, BIOS: BIOS{Version: "3.4"}},
+			wantCurrent:  "3.4",
+			wantOutdated: false,
+		},
+		{
+			name:         "unknown revision",
+			machine:      &Machine{Base: Base{ID: "m4"}},
+			wantCurrent:  "",
+			wantOutdated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := catalog.Compliance(tt.machine)
+			require.Equal(t, tt.wantCurrent, got.Current)
+			require.Equal(t, tt.wantOutdated, got.Outdated)
+			require.Equal(t, "3.3", got.Target)
+		})
+	}
+}
+
+func TestFirmwareCatalog_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog *FirmwareCatalog
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			catalog: &FirmwareCatalog{Kind: FirmwareKindBMC, Vendor: "Supermicro", Board: "X11DPT-B", Target: "1.73.07", Revisions: []FirmwareRevision{{Revision: "1.73.07", URL: "http://firmware/bmc.bin"}}},
+		},
+		{
+			name:    "invalid kind",
+			catalog: &FirmwareCatalog{Kind: "nic", Vendor: "Supermicro", Board: "X11DPT-B"},
+			wantErr: `invalid kind:"nic", must be one of [bios bmc]`,
+		},
+		{
+			name:    "duplicate revision",
+			catalog: &FirmwareCatalog{Kind: FirmwareKindBMC, Vendor: "Supermicro", Board: "X11DPT-B", Revisions: []FirmwareRevision{{Revision: "1.73.07", URL: "http://a"}, {Revision: "1.73.07", URL: "http://b"}}},
+			wantErr: `revision "1.73.07" is contained more than once`,
+		},
+		{
+			name:    "unknown target",
+			catalog: &FirmwareCatalog{Kind: FirmwareKindBMC, Vendor: "Supermicro", Board: "X11DPT-B", Target: "1.74", Revisions: []FirmwareRevision{{Revision: "1.73.07", URL: "http://a"}}},
+			wantErr: `target "1.74" must be one of the revisions [1.73.07]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.catalog.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -22,6 +22,15 @@ type Image struct {
 	// Classification defines the state of a version (preview, supported, deprecated)
 	// only informational, no action depending on the classification done
 	Classification VersionClassification `rethinkdb:"classification"`
+	// Size of the image in bytes as announced by the image server during the last verification
+	Size int64 `rethinkdb:"size"`
+	// Checksum is the md5 checksum of the image from the last verification, empty if no checksum file was found
+	Checksum string `rethinkdb:"checksum"`
+	// LastVerified is the time when the image url was verified successfully for the last time,
+	// the periodic verification only updates it if the size, checksum or reachability changed
+	LastVerified time.Time `rethinkdb:"lastVerified"`
+	// VerificationError is set if the periodic verification found the image url to be unreachable
+	VerificationError string `rethinkdb:"verificationError"`
 }
 
 // DefaultImageExpiration if not specified images will last for about 3 month
@@ -0,0 +1,151 @@
+package metal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type (
+	HardwareComponent string
+
+	// MachineHardwareSnapshot is a version of the hardware a machine reported on registration.
+	// A new revision is only stored if the hardware changed compared to the previous revision.
+	MachineHardwareSnapshot struct {
+		Base
+		Machine  string          `rethinkdb:"machine"`
+		Revision int             `rethinkdb:"revision"`
+		Taken    time.Time       `rethinkdb:"taken"`
+		Hardware MachineHardware `rethinkdb:"hardware"`
+		BIOS     BIOS            `rethinkdb:"bios"`
+		// Changes contains the changes compared to the previous revision
+		Changes []HardwareChange `rethinkdb:"changes"`
+	}
+
+	// HardwareChange describes a change of a hardware component of a machine between two registrations.
+	HardwareChange struct {
+		Component HardwareComponent `rethinkdb:"component"`
+		Message   string            `rethinkdb:"message"`
+	}
+)
+
+const (
+	HardwareComponentMemory HardwareComponent = "memory"
+	HardwareComponentDisk   HardwareComponent = "disk"
+	HardwareComponentNic    HardwareComponent = "nic"
+	HardwareComponentCPU    HardwareComponent = "cpu"
+	HardwareComponentGPU    HardwareComponent = "gpu"
+	HardwareComponentBIOS   HardwareComponent = "bios"
+)
+
+// MachineHardwareSnapshotID returns the id of the given revision of the hardware snapshots of a machine.
+func MachineHardwareSnapshotID(machineID string, revision int) string {
+	return fmt.Sprintf("%s:%d", machineID, revision)
+}
+
+// DiffHardware compares the hardware and bios a machine reported on its previous registration with the current one.
+// Changes of the memory, disks, nics, cpus, gpus and the bios version are returned, neighbors of the nics are not
+// considered as they belong to the switches. No changes are returned if the machine was not registered before.
+func DiffHardware(prevHardware MachineHardware, prevBIOS BIOS, hardware MachineHardware, bios BIOS) []HardwareChange {
+	if prevHardware.Memory == 0 && len(prevHardware.Disks) == 0 && len(prevHardware.Nics) == 0 && prevBIOS == (BIOS{}) {
+		return nil
+	}
+
+	var changes []HardwareChange
+
+	if prevHardware.Memory != hardware.Memory {
+		changes = append(changes, HardwareChange{
+			Component: HardwareComponentMemory,
+			Message:   fmt.Sprintf("memory changed from %s to %s", humanize.Bytes(prevHardware.Memory), humanize.Bytes(hardware.Memory)),
+		})
+	}
+
+	changes = append(changes, diffByName(HardwareComponentDisk, prevHardware.Disks, hardware.Disks,
+		func(d BlockDevice) string { return d.Name },
+		func(d BlockDevice) string { return humanize.Bytes(d.Size) },
+	)...)
+
+	changes = append(changes, diffByName(HardwareComponentNic, prevHardware.Nics, hardware.Nics,
+		func(n Nic) string { return n.Name },
+		func(n Nic) string { return n.MacAddress },
+	)...)
+
+	cpuModels := func(cpus []MetalCPU) []string {
+		var result []string
+		for _, cpu := range cpus {
+			result = append(result, fmt.Sprintf("%s %s (%d cores, %d threads)", cpu.Vendor, cpu.Model, cpu.Cores, cpu.Threads))
+		}
+		slices.Sort(result)
+		return result
+	}
+	if prev, cur := cpuModels(prevHardware.MetalCPUs), cpuModels(hardware.MetalCPUs); !slices.Equal(prev, cur) {
+		changes = append(changes, HardwareChange{
+			Component: HardwareComponentCPU,
+			Message:   fmt.Sprintf("cpus changed from %v to %v", prev, cur),
+		})
+	}
+
+	gpuModels := func(gpus []MetalGPU) []string {
+		var result []string
+		for _, gpu := range gpus {
+			result = append(result, gpu.Vendor+" "+gpu.Model)
+		}
+		slices.Sort(result)
+		return result
+	}
+	if prev, cur := gpuModels(prevHardware.MetalGPUs), gpuModels(hardware.MetalGPUs); !slices.Equal(prev, cur) {
+		changes = append(changes, HardwareChange{
+			Component: HardwareComponentGPU,
+			Message:   fmt.Sprintf("gpus changed from %v to %v", prev, cur),
+		})
+	}
+
+	if prevBIOS.Version != bios.Version {
+		changes = append(changes, HardwareChange{
+			Component: HardwareComponentBIOS,
+			Message:   fmt.Sprintf("bios version changed from %q to %q", prevBIOS.Version, bios.Version),
+		})
+	}
+
+	return changes
+}
+
+// diffByName compares components which are identified by their name and returns the removed, added and changed ones
+// in the order of their names. value returns the property of a component whose change is reported.
+func diffByName[E any](component HardwareComponent, prev, cur []E, name, value func(E) string) []HardwareChange {
+	var (
+		changes  []HardwareChange
+		prevByID = map[string]string{}
+		curByID  = map[string]string{}
+		names    []string
+	)
+
+	for _, e := range prev {
+		prevByID[name(e)] = value(e)
+		names = append(names, name(e))
+	}
+	for _, e := range cur {
+		curByID[name(e)] = value(e)
+		names = append(names, name(e))
+	}
+
+	slices.Sort(names)
+
+	for _, n := range slices.Compact(names) {
+		prevValue, wasPresent := prevByID[n]
+		curValue, isPresent := curByID[n]
+
+		switch {
+		case !isPresent:
+			changes = append(changes, HardwareChange{Component: component, Message: fmt.Sprintf("%s %s (%s) was removed", component, n, prevValue)})
+		case !wasPresent:
+			changes = append(changes, HardwareChange{Component: component, Message: fmt.Sprintf("%s %s (%s) was added", component, n, curValue)})
+		case prevValue != curValue:
+			changes = append(changes, HardwareChange{Component: component, Message: fmt.Sprintf("%s %s changed from %s to %s", component, n, prevValue, curValue)})
+		}
+	}
+
+	return changes
+}
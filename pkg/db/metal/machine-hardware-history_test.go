@@ -0,0 +1,110 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffHardware(t *testing.T) {
+	var (
+		bios     = BIOS{Vendor: "Supermicro", Version: "2.1"}
+		hardware = MachineHardware{
+			Memory: 256 << 30,
+			Disks: []BlockDevice{
+				{Name: "/dev/sda", Size: 960 << 30},
+				{Name: "/dev/sdb", Size: 960 << 30},
+			},
+			Nics: Nics{
+				{Name: "lan0", MacAddress: "aa:aa:aa:aa:aa:01", Neighbors: Nics{{Name: "swp1", MacAddress: "bb:bb:bb:bb:bb:01"}}},
+				{Name: "lan1", MacAddress: "aa:aa:aa:aa:aa:02"},
+			},
+			MetalCPUs: []MetalCPU{{Vendor: "AMD", Model: "EPYC 7402P", Cores: 24, Threads: 48}},
+		}
+	)
+
+	tests := []struct {
+		name         string
+		prevHardware MachineHardware
+		prevBIOS     BIOS
+		hardware     func() MachineHardware
+		bios         BIOS
+		want         []HardwareChange
+	}{
+		{
+			name:         "first registration",
+			prevHardware: MachineHardware{},
+			prevBIOS:     BIOS{},
+			hardware:     func() MachineHardware { return hardware },
+			bios:         bios,
+			want:         nil,
+		},
+		{
+			name:         "nothing changed",
+			prevHardware: hardware,
+			prevBIOS:     bios,
+			hardware:     func() MachineHardware { return hardware },
+			bios:         bios,
+			want:         nil,
+		},
+		{
+			name:         "changed neighbors are ignored",
+			prevHardware: hardware,
+			prevBIOS:     bios,
+			hardware: func() MachineHardware {
+				h := hardware
+				h.Nics = Nics{
+					{Name: "lan0", MacAddress: "aa:aa:aa:aa:aa:01", Neighbors: Nics{{Name: "swp2", MacAddress: "bb:bb:bb:bb:bb:02"}}},
+					{Name: "lan1", MacAddress: "aa:aa:aa:aa:aa:02"},
+				}
+				return h
+			},
+			bios: bios,
+			want: nil,
+		},
+		{
+			name:         "swapped dimm, missing disk, changed mac and bios update",
+			prevHardware: hardware,
+			prevBIOS:     bios,
+			hardware: func() MachineHardware {
+				h := hardware
+				h.Memory = 240 << 30
+				h.Disks = []BlockDevice{{Name: "/dev/sda", Size: 960 << 30}}
+				h.Nics = Nics{
+					{Name: "lan0", MacAddress: "aa:aa:aa:aa:aa:01"},
+					{Name: "lan1", MacAddress: "aa:aa:aa:aa:aa:03"},
+				}
+				return h
+			},
+			bios: BIOS{Vendor: "Supermicro", Version: "2.2"},
+			want: []HardwareChange{
+				{Component: HardwareComponentMemory, Message: "memory changed from 275 GB to 258 GB"},
+				{Component: HardwareComponentDisk, Message: "disk /dev/sdb (1.0 TB) was removed"},
+				{Component: HardwareComponentNic, Message: "nic lan1 changed from aa:aa:aa:aa:aa:02 to aa:aa:aa:aa:aa:03"},
+				{Component: HardwareComponentBIOS, Message: `bios version changed from "2.1" to "2.2"`},
+			},
+		},
+		{
+			name:         "added disk and gpu",
+			prevHardware: hardware,
+			prevBIOS:     bios,
+			hardware: func() MachineHardware {
+				h := hardware
+				h.Disks = append(h.Disks, BlockDevice{Name: "/dev/nvme0n1", Size: 2 << 40})
+				h.MetalGPUs = []MetalGPU{{Vendor: "NVIDIA", Model: "H100"}}
+				return h
+			},
+			bios: bios,
+			want: []HardwareChange{
+				{Component: HardwareComponentDisk, Message: "disk /dev/nvme0n1 (2.2 TB) was added"},
+				{Component: HardwareComponentGPU, Message: "gpus changed from [] to [NVIDIA H100]"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffHardware(tt.prevHardware, tt.prevBIOS, tt.hardware(), tt.bios)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
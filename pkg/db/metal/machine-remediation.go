@@ -0,0 +1,97 @@
+package metal
+
+import (
+	"fmt"
+)
+
+type (
+	// RemediationTrigger is an irregularity in the lifecycle of a machine which is remediated automatically.
+	RemediationTrigger string
+	// RemediationAction is taken against a machine once a trigger occurred often enough.
+	RemediationAction string
+
+	// MachineRemediationPolicy configures how machines of a partition are remediated, the ID of a policy is the id
+	// of its partition. Machines of partitions without a policy are not remediated.
+	MachineRemediationPolicy struct {
+		Base
+		// PowerCycleAfter is the number of crash loops after which a machine is power cycled, disabled if zero
+		PowerCycleAfter int `rethinkdb:"power_cycle_after"`
+		// TaintAfter is the number of crash loops or failed reclaims after which a machine is tainted, disabled if zero
+		TaintAfter int `rethinkdb:"taint_after"`
+		// ReclaimRetries is the number of times the pxe boot of a machine which failed to be reclaimed is retried, disabled if zero
+		ReclaimRetries int `rethinkdb:"reclaim_retries"`
+	}
+)
+
+const (
+	RemediationTriggerCrashLoop            RemediationTrigger = "crashloop"
+	RemediationTriggerFailedMachineReclaim RemediationTrigger = "failed-machine-reclaim"
+
+	RemediationActionNone         RemediationAction = ""
+	RemediationActionPowerCycle   RemediationAction = "power-cycle"
+	RemediationActionRetryPXEBoot RemediationAction = "retry-pxe-boot"
+	RemediationActionTaint        RemediationAction = "taint"
+)
+
+// Validate checks that the thresholds of the policy are not negative and that a machine is power cycled before it is tainted.
+func (p *MachineRemediationPolicy) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("partition must not be empty")
+	}
+	if p.PowerCycleAfter < 0 || p.TaintAfter < 0 || p.ReclaimRetries < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	if p.PowerCycleAfter > 0 && p.TaintAfter > 0 && p.TaintAfter <= p.PowerCycleAfter {
+		return fmt.Errorf("taint after:%d must be greater than power cycle after:%d", p.TaintAfter, p.PowerCycleAfter)
+	}
+	return nil
+}
+
+// Action returns the action which is taken against a machine on which the trigger occurred count times in a row.
+// Tainting takes precedence over all other actions.
+func (p *MachineRemediationPolicy) Action(trigger RemediationTrigger, count int) RemediationAction {
+	if p.TaintAfter > 0 && count >= p.TaintAfter {
+		return RemediationActionTaint
+	}
+
+	switch trigger {
+	case RemediationTriggerCrashLoop:
+		if p.PowerCycleAfter > 0 && count >= p.PowerCycleAfter {
+			return RemediationActionPowerCycle
+		}
+	case RemediationTriggerFailedMachineReclaim:
+		if count <= p.ReclaimRetries {
+			return RemediationActionRetryPXEBoot
+		}
+	}
+
+	return RemediationActionNone
+}
+
+// CountRemediationTriggers updates the crash loop and failed machine reclaim counters of the container after the event
+// was handled by the fsm, previous is the container before the event. It returns the trigger which occurred with the
+// event and how often it occurred in a row, the trigger is empty if none occurred.
+//
+// A crash loop is counted once when the machine enters it, the counter is reset once the machine phoned home or waits
+// for an allocation again. A failed reclaim is counted once when it is detected, the counter is reset once the machine
+// waits for an allocation again, retrying the pxe boot must not reset it.
+func (ec *ProvisioningEventContainer) CountRemediationTriggers(previous *ProvisioningEventContainer, event *ProvisioningEvent) (RemediationTrigger, int) {
+	switch event.Event {
+	case ProvisioningEventWaiting:
+		ec.CrashLoops = 0
+		ec.FailedMachineReclaims = 0
+	case ProvisioningEventPhonedHome:
+		ec.CrashLoops = 0
+	}
+
+	switch {
+	case ec.CrashLoop && !previous.CrashLoop:
+		ec.CrashLoops++
+		return RemediationTriggerCrashLoop, ec.CrashLoops
+	case ec.FailedMachineReclaim && !previous.FailedMachineReclaim:
+		ec.FailedMachineReclaims++
+		return RemediationTriggerFailedMachineReclaim, ec.FailedMachineReclaims
+	default:
+		return "", 0
+	}
+}
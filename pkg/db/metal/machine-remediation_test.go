@@ -0,0 +1,128 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineRemediationPolicy_Action(t *testing.T) {
+	policy := &MachineRemediationPolicy{
+		Base:            Base{ID: "partition-a"},
+		PowerCycleAfter: 2,
+		TaintAfter:      4,
+		ReclaimRetries:  2,
+	}
+
+	tests := []struct {
+		name    string
+		trigger RemediationTrigger
+		count   int
+		want    RemediationAction
+	}{
+		{name: "first crash loop", trigger: RemediationTriggerCrashLoop, count: 1, want: RemediationActionNone},
+		{name: "power cycle", trigger: RemediationTriggerCrashLoop, count: 2, want: RemediationActionPowerCycle},
+		{name: "power cycle again", trigger: RemediationTriggerCrashLoop, count: 3, want: RemediationActionPowerCycle},
+		{name: "taint crash loop", trigger: RemediationTriggerCrashLoop, count: 4, want: RemediationActionTaint},
+		{name: "retry pxe boot", trigger: RemediationTriggerFailedMachineReclaim, count: 1, want: RemediationActionRetryPXEBoot},
+		{name: "retries exhausted", trigger: RemediationTriggerFailedMachineReclaim, count: 3, want: RemediationActionNone},
+		{name: "taint failed reclaim", trigger: RemediationTriggerFailedMachineReclaim, count: 4, want: RemediationActionTaint},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, policy.Action(tt.trigger, tt.count))
+		})
+	}
+
+	require.Equal(t, RemediationActionNone, (&MachineRemediationPolicy{}).Action(RemediationTriggerCrashLoop, 10))
+}
+
+func TestMachineRemediationPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *MachineRemediationPolicy
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			policy: &MachineRemediationPolicy{Base: Base{ID: "partition-a"}, PowerCycleAfter: 2, TaintAfter: 4, ReclaimRetries: 1},
+		},
+		{
+			name:    "no partition",
+			policy:  &MachineRemediationPolicy{PowerCycleAfter: 2},
+			wantErr: "partition must not be empty",
+		},
+		{
+			name:    "negative",
+			policy:  &MachineRemediationPolicy{Base: Base{ID: "partition-a"}, ReclaimRetries: -1},
+			wantErr: "thresholds must not be negative",
+		},
+		{
+			name:    "taint before power cycle",
+			policy:  &MachineRemediationPolicy{Base: Base{ID: "partition-a"}, PowerCycleAfter: 3, TaintAfter: 3},
+			wantErr: "taint after:3 must be greater than power cycle after:3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestProvisioningEventContainer_CountRemediationTriggers(t *testing.T) {
+	var (
+		pxe     = &ProvisioningEvent{Event: ProvisioningEventPXEBooting}
+		crashed = &ProvisioningEvent{Event: ProvisioningEventCrashed}
+		phoned  = &ProvisioningEvent{Event: ProvisioningEventPhonedHome}
+		waiting = &ProvisioningEvent{Event: ProvisioningEventWaiting}
+	)
+
+	ec := &ProvisioningEventContainer{CrashLoop: true, CrashLoops: 1}
+	trigger, count := ec.CountRemediationTriggers(&ProvisioningEventContainer{}, pxe)
+	require.Equal(t, RemediationTriggerCrashLoop, trigger)
+	require.Equal(t, 2, count)
+
+	// further events while crash looping belong to the same crash loop
+	trigger, count = ec.CountRemediationTriggers(&ProvisioningEventContainer{CrashLoop: true}, crashed)
+	require.Empty(t, trigger)
+	require.Zero(t, count)
+	trigger, _ = ec.CountRemediationTriggers(&ProvisioningEventContainer{CrashLoop: true}, pxe)
+	require.Empty(t, trigger)
+	require.Equal(t, 2, ec.CrashLoops)
+
+	// the crash loop ended without the machine being healthy again, e.g. by a reclaim
+	ec.CrashLoop = false
+	trigger, _ = ec.CountRemediationTriggers(&ProvisioningEventContainer{CrashLoop: true}, crashed)
+	require.Empty(t, trigger)
+	require.Equal(t, 2, ec.CrashLoops)
+
+	ec.CrashLoop = true
+	trigger, count = ec.CountRemediationTriggers(&ProvisioningEventContainer{}, pxe)
+	require.Equal(t, RemediationTriggerCrashLoop, trigger)
+	require.Equal(t, 3, count)
+
+	ec.CrashLoop = false
+	trigger, _ = ec.CountRemediationTriggers(&ProvisioningEventContainer{CrashLoop: true}, phoned)
+	require.Empty(t, trigger)
+	require.Zero(t, ec.CrashLoops)
+
+	ec = &ProvisioningEventContainer{FailedMachineReclaim: true, FailedMachineReclaims: 1}
+	trigger, count = ec.CountRemediationTriggers(&ProvisioningEventContainer{}, phoned)
+	require.Equal(t, RemediationTriggerFailedMachineReclaim, trigger)
+	require.Equal(t, 2, count)
+
+	// a failed reclaim is only counted once when it is detected
+	trigger, _ = ec.CountRemediationTriggers(&ProvisioningEventContainer{FailedMachineReclaim: true}, phoned)
+	require.Empty(t, trigger)
+	require.Equal(t, 2, ec.FailedMachineReclaims)
+
+	ec.FailedMachineReclaim = false
+	_, _ = ec.CountRemediationTriggers(&ProvisioningEventContainer{}, waiting)
+	require.Zero(t, ec.FailedMachineReclaims)
+}
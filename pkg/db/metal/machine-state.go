@@ -0,0 +1,19 @@
+package metal
+
+// MaxMachineStateHistory is the number of previous states which are kept in the state history of a machine.
+const MaxMachineStateHistory = 50
+
+// ChangeState sets the state of the machine and keeps the previous state in the state history.
+func (m *Machine) ChangeState(state MachineState) {
+	// the initial available state of a machine was never set by anyone and is not worth keeping
+	if m.State.Value != AvailableState || m.State.Issuer != "" || !m.State.Changed.IsZero() {
+		m.StateHistory = append([]MachineState{m.State}, m.StateHistory...)
+	}
+
+	if len(m.StateHistory) > MaxMachineStateHistory {
+		m.StateHistory = m.StateHistory[:MaxMachineStateHistory]
+	}
+
+	state.MetalHammerVersion = m.State.MetalHammerVersion
+	m.State = state
+}
@@ -0,0 +1,30 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachine_ChangeState(t *testing.T) {
+	now := time.Now()
+
+	m := &Machine{State: MachineState{MetalHammerVersion: "v0.13.0"}}
+
+	m.ChangeState(MachineState{Value: LockedState, Description: "debugging", Issuer: "alice", Changed: now})
+	require.Empty(t, m.StateHistory, "initial state must not be kept")
+	require.Equal(t, "v0.13.0", m.State.MetalHammerVersion)
+
+	m.ChangeState(MachineState{Issuer: "bob", Changed: now.Add(time.Minute)})
+	require.Equal(t, []MachineState{
+		{Value: LockedState, Description: "debugging", Issuer: "alice", MetalHammerVersion: "v0.13.0", Changed: now},
+	}, m.StateHistory)
+	require.Equal(t, MachineState{Issuer: "bob", MetalHammerVersion: "v0.13.0", Changed: now.Add(time.Minute)}, m.State)
+
+	for range MaxMachineStateHistory + 10 {
+		m.ChangeState(MachineState{Value: TaintedState, Issuer: "carol", Changed: now})
+	}
+	require.Len(t, m.StateHistory, MaxMachineStateHistory)
+	require.Equal(t, "carol", m.StateHistory[MaxMachineStateHistory-1].Issuer)
+}
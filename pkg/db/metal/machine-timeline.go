@@ -0,0 +1,59 @@
+package metal
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	MachineTimelineEntryType string
+
+	// MachineTimelineEntry is an archived entry of the lifecycle of a machine. Provisioning events are archived when they are
+	// evicted from the provisioning event container, allocations, releases, state changes, remediations and console accesses are archived when they happen.
+	MachineTimelineEntry struct {
+		Base
+		Machine string                   `rethinkdb:"machine"`
+		Time    time.Time                `rethinkdb:"time"`
+		Type    MachineTimelineEntryType `rethinkdb:"type"`
+		// Event is only set for provisioning events
+		Event   ProvisioningEventType `rethinkdb:"event"`
+		Project string                `rethinkdb:"project"`
+		Issuer  string                `rethinkdb:"issuer"`
+		Message string                `rethinkdb:"message"`
+	}
+)
+
+const (
+	MachineTimelineProvisioningEvent MachineTimelineEntryType = "provisioning-event"
+	MachineTimelineAllocation        MachineTimelineEntryType = "allocation"
+	MachineTimelineRelease           MachineTimelineEntryType = "release"
+	MachineTimelineStateChange       MachineTimelineEntryType = "state-change"
+	MachineTimelineRemediation       MachineTimelineEntryType = "remediation"
+	MachineTimelineConsoleAccess     MachineTimelineEntryType = "console-access"
+)
+
+// MachineTimelineEntryID returns the id of a timeline entry, it is derived from the machine, the type and the time
+// of the entry such that archiving an entry twice does not duplicate it.
+func MachineTimelineEntryID(machineID string, t MachineTimelineEntryType, at time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", machineID, t, at.UnixNano())
+}
+
+// TimelineEntries converts provisioning events of a machine into timeline entries.
+func (es ProvisioningEvents) TimelineEntries(machineID string) []*MachineTimelineEntry {
+	var result []*MachineTimelineEntry
+
+	for _, e := range es {
+		result = append(result, &MachineTimelineEntry{
+			Base: Base{
+				ID: MachineTimelineEntryID(machineID, MachineTimelineProvisioningEvent, e.Time),
+			},
+			Machine: machineID,
+			Time:    e.Time,
+			Type:    MachineTimelineProvisioningEvent,
+			Event:   e.Event,
+			Message: e.Message,
+		})
+	}
+
+	return result
+}
@@ -28,6 +28,10 @@ type Machine struct {
 	Tags         []string                `rethinkdb:"tags"`
 	IPMI         IPMI                    `rethinkdb:"ipmi"`
 	BIOS         BIOS                    `rethinkdb:"bios"`
+	// HardwareChanges contains the hardware changes detected on registration which were not acknowledged yet
+	HardwareChanges []HardwareChange `rethinkdb:"hardware_changes"`
+	// StateHistory contains the previous states of the machine starting with the latest
+	StateHistory []MachineState `rethinkdb:"state_history"`
 }
 
 // A MachineAllocation stores the data which are only present for allocated machines.
@@ -66,6 +70,8 @@ type MachineState struct {
 	Description        string `rethinkdb:"description"`
 	Issuer             string `rethinkdb:"issuer"`
 	MetalHammerVersion string `rethinkdb:"metal_hammer_version"`
+	// Changed is the time the state was set
+	Changed time.Time `rethinkdb:"changed"`
 }
 
 // A MState is an enum which indicates the state of a machine
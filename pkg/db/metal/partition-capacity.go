@@ -0,0 +1,17 @@
+package metal
+
+import "time"
+
+// PartitionCapacitySnapshot records the capacity of a size in a partition at a point in time,
+// the snapshots of a partition and size form the trend of its free machines.
+type PartitionCapacitySnapshot struct {
+	Base
+	Partition    string    `rethinkdb:"partition"`
+	Size         string    `rethinkdb:"size"`
+	Taken        time.Time `rethinkdb:"taken"`
+	Total        int64     `rethinkdb:"total"`
+	Free         int64     `rethinkdb:"free"`
+	Allocated    int64     `rethinkdb:"allocated"`
+	Reservations int64     `rethinkdb:"reservations"`
+	Faulty       int64     `rethinkdb:"faulty"`
+}
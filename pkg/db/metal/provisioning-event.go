@@ -26,6 +26,9 @@ type (
 		LastErrorEvent       *ProvisioningEvent `rethinkdb:"last_error_event"`
 		CrashLoop            bool               `rethinkdb:"crash_loop"`
 		FailedMachineReclaim bool               `rethinkdb:"failed_machine_reclaim"`
+		// CrashLoops and FailedMachineReclaims count the occurrences in a row for the automatic remediation
+		CrashLoops            int `rethinkdb:"crash_loops"`
+		FailedMachineReclaims int `rethinkdb:"failed_machine_reclaims"`
 	}
 )
 
@@ -88,10 +91,16 @@ func FromProvisioningEventType(t ProvisioningEventType) (apiv2.MachineProvisioni
 	return apiv2Type, nil
 }
 
-func (p *ProvisioningEventContainer) TrimEvents(maxCount int) {
-	if len(p.Events) > maxCount {
-		p.Events = p.Events[:maxCount]
+// TrimEvents keeps the latest maxCount events in the container and returns the evicted ones.
+func (p *ProvisioningEventContainer) TrimEvents(maxCount int) ProvisioningEvents {
+	if len(p.Events) <= maxCount {
+		return nil
 	}
+
+	evicted := p.Events[maxCount:]
+	p.Events = p.Events[:maxCount]
+
+	return evicted
 }
 
 func (c *ProvisioningEventContainer) Validate() error {
@@ -1,8 +1,11 @@
 package metal
 
 import (
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestProvisioningEventContainer_Validate(t *testing.T) {
@@ -114,3 +117,43 @@ func TestProvisioningEventContainer_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestProvisioningEventContainer_TrimEvents(t *testing.T) {
+	now := time.Now()
+	events := ProvisioningEvents{
+		{Time: now, Event: ProvisioningEventPhonedHome},
+		{Time: now.Add(-time.Minute), Event: ProvisioningEventInstalling},
+		{Time: now.Add(-2 * time.Minute), Event: ProvisioningEventPXEBooting},
+	}
+
+	tests := []struct {
+		name        string
+		maxCount    int
+		wantEvents  ProvisioningEvents
+		wantEvicted ProvisioningEvents
+	}{
+		{
+			name:       "nothing evicted",
+			maxCount:   3,
+			wantEvents: events,
+		},
+		{
+			name:        "oldest events are evicted",
+			maxCount:    1,
+			wantEvents:  events[:1],
+			wantEvicted: events[1:],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProvisioningEventContainer{Events: slices.Clone(events)}
+			evicted := p.TrimEvents(tt.maxCount)
+			if diff := cmp.Diff(tt.wantEvents, p.Events); diff != "" {
+				t.Errorf("TrimEvents() events diff = %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvicted, evicted); diff != "" {
+				t.Errorf("TrimEvents() evicted diff = %s", diff)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package queries
 
 import (
+	"time"
+
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
@@ -15,3 +17,11 @@ func EventFilter(machineID string) func(q r.Term) r.Term {
 		return q
 	}
 }
+
+// MachineTimelineExpired returns the archived timeline entries of all machines which happened before the given time,
+// it is evaluated on the time index of the table and must therefore be the first query.
+func MachineTimelineExpired(before time.Time) func(q r.Term) r.Term {
+	return func(q r.Term) r.Term {
+		return q.Between(r.MinVal, before, r.BetweenOpts{Index: "time"})
+	}
+}
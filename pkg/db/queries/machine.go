@@ -380,3 +380,19 @@ func MachineFilter(rq *apiv2.MachineQuery) func(q r.Term) r.Term {
 		return q
 	}
 }
+
+// MachineHardwareSnapshotFilter returns the hardware snapshots of a machine ordered by their revision starting with the latest,
+// if limit is positive only the latest snapshots are returned.
+func MachineHardwareSnapshotFilter(machineID string, limit int) func(q r.Term) r.Term {
+	return func(q r.Term) r.Term {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machine").Eq(machineID)
+		}).OrderBy(r.Desc("revision"))
+
+		if limit > 0 {
+			return q.Limit(limit)
+		}
+
+		return q
+	}
+}
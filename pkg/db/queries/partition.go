@@ -1,6 +1,8 @@
 package queries
 
 import (
+	"time"
+
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
@@ -18,3 +20,30 @@ func PartitionFilter(rq *apiv2.PartitionQuery) func(q r.Term) r.Term {
 		return q
 	}
 }
+
+// PartitionCapacitySnapshotFilter returns the capacity snapshots taken since the given time ordered by the time they were taken.
+// If limit is positive, only the latest snapshots are returned.
+func PartitionCapacitySnapshotFilter(since time.Time, limit int) func(q r.Term) r.Term {
+	return func(q r.Term) r.Term {
+		if !since.IsZero() {
+			q = q.Filter(func(row r.Term) r.Term {
+				return row.Field("taken").Ge(since)
+			})
+		}
+
+		if limit > 0 {
+			return q.OrderBy(r.Desc("taken")).Limit(limit).OrderBy(r.Asc("taken"))
+		}
+
+		return q.OrderBy(r.Asc("taken"))
+	}
+}
+
+// PartitionCapacitySnapshotExpired returns the capacity snapshots which were taken before the given time.
+func PartitionCapacitySnapshotExpired(before time.Time) func(q r.Term) r.Term {
+	return func(q r.Term) r.Term {
+		return q.Filter(func(row r.Term) r.Term {
+			return row.Field("taken").Lt(before)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package imageverify
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// ChecksumSuffix is appended to the image url to get the md5 checksum file, as it is done by metal-hammer
+	ChecksumSuffix = ".md5"
+	// maxChecksumFileSize limits the download of checksum files, they only contain the checksum and the file name
+	maxChecksumFileSize = 4096
+)
+
+type (
+	Config struct {
+		// Client is used for all requests, defaults to a client with a timeout of 10 seconds
+		Client *http.Client
+		// RequireChecksum fails the verification if the checksum file of an image is missing or invalid
+		RequireChecksum bool
+	}
+
+	// Verifier checks that images are reachable under their url before they are used for machine installations.
+	Verifier struct {
+		client          *http.Client
+		requireChecksum bool
+	}
+
+	// Result contains the details of a successful verification.
+	Result struct {
+		// Size of the image in bytes, -1 if the server does not announce it
+		Size int64
+		// Checksum is the md5 checksum of the image, empty if no checksum file exists and it is not required
+		Checksum string
+		// VerifiedAt is the time of the verification
+		VerifiedAt time.Time
+	}
+)
+
+func New(c Config) *Verifier {
+	client := c.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: defaultTimeout,
+		}
+	}
+
+	return &Verifier{
+		client:          client,
+		requireChecksum: c.RequireChecksum,
+	}
+}
+
+// Verify requests the headers of the image and downloads its checksum file.
+// The returned errors do not mention the image, callers are expected to prefix them with the image id.
+func (v *Verifier) Verify(ctx context.Context, url string) (*Result, error) {
+	resp, err := v.do(ctx, http.MethodHead, url)
+	if err != nil {
+		return nil, fmt.Errorf("is not accessible under:%s error:%w", url, err)
+	}
+	_ = resp.Body.Close()
+
+	// Consider 2xx and 3xx status codes as available
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("is not accessible under:%s statuscode:%d", url, resp.StatusCode)
+	}
+
+	result := &Result{
+		Size:       resp.ContentLength,
+		VerifiedAt: time.Now(),
+	}
+
+	checksum, err := v.checksum(ctx, url+ChecksumSuffix)
+	if err != nil {
+		if v.requireChecksum {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	result.Checksum = checksum
+
+	return result, nil
+}
+
+func (v *Verifier) checksum(ctx context.Context, url string) (string, error) {
+	resp, err := v.do(ctx, http.MethodGet, url)
+	if err != nil {
+		return "", fmt.Errorf("checksum is not accessible under:%s error:%w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("checksum is not accessible under:%s statuscode:%d", url, resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumFileSize))
+	if err != nil {
+		return "", fmt.Errorf("unable to read checksum under:%s error:%w", url, err)
+	}
+
+	return parseChecksum(string(raw))
+}
+
+func (v *Verifier) do(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	return v.client.Do(req)
+}
+
+// parseChecksum accepts the output of md5sum, which is the checksum optionally followed by the file name.
+func parseChecksum(content string) (string, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file is empty")
+	}
+
+	checksum := strings.ToLower(fields[0])
+	decoded, err := hex.DecodeString(checksum)
+	if err != nil || len(decoded) != 16 {
+		return "", fmt.Errorf("checksum file does not contain a md5 checksum")
+	}
+
+	return checksum, nil
+}
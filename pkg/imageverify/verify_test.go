@@ -0,0 +1,85 @@
+package imageverify
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	const checksum = "d41d8cd98f00b204e9800998ecf8427e"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ubuntu/24.04/img.tar.lz4", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+	})
+	mux.HandleFunc("/ubuntu/24.04/img.tar.lz4.md5", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s  img.tar.lz4\n", checksum)
+	})
+	mux.HandleFunc("/debian/12/img.tar.lz4", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2048")
+	})
+	mux.HandleFunc("/firewall/3/img.tar.lz4", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/firewall/3/img.tar.lz4.md5", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "not a checksum")
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		url             string
+		requireChecksum bool
+		want            *Result
+		wantErr         string
+	}{
+		{
+			name:            "image with checksum",
+			url:             ts.URL + "/ubuntu/24.04/img.tar.lz4",
+			requireChecksum: true,
+			want:            &Result{Size: 1024, Checksum: checksum},
+		},
+		{
+			name:    "image does not exist",
+			url:     ts.URL + "/ubuntu/2404/img.tar.lz4",
+			wantErr: fmt.Sprintf("is not accessible under:%s/ubuntu/2404/img.tar.lz4 statuscode:404", ts.URL),
+		},
+		{
+			name:            "checksum is missing",
+			url:             ts.URL + "/debian/12/img.tar.lz4",
+			requireChecksum: true,
+			wantErr:         fmt.Sprintf("checksum is not accessible under:%s/debian/12/img.tar.lz4.md5 statuscode:404", ts.URL),
+		},
+		{
+			name: "checksum is missing but not required",
+			url:  ts.URL + "/debian/12/img.tar.lz4",
+			want: &Result{Size: 2048},
+		},
+		{
+			name:            "checksum is invalid",
+			url:             ts.URL + "/firewall/3/img.tar.lz4",
+			requireChecksum: true,
+			wantErr:         "checksum file does not contain a md5 checksum",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(Config{Client: ts.Client(), RequireChecksum: tt.requireChecksum})
+
+			got, err := v.Verify(t.Context(), tt.url)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.NotZero(t, got.VerifiedAt)
+			got.VerifiedAt = tt.want.VerifiedAt
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
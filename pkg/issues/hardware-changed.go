@@ -0,0 +1,44 @@
+package issues
+
+import (
+	"strings"
+
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+)
+
+const (
+	TypeHardwareChanged Type = "hardware-changed"
+)
+
+type (
+	issueHardwareChanged struct {
+		details string
+	}
+)
+
+func (i *issueHardwareChanged) Details() string {
+	return i.details
+}
+
+func (i *issueHardwareChanged) Evaluate(m *metal.Machine, ec *metal.ProvisioningEventContainer, c *Config) bool {
+	if len(m.HardwareChanges) == 0 {
+		return false
+	}
+
+	var changes []string
+	for _, change := range m.HardwareChanges {
+		changes = append(changes, "- "+change.Message)
+	}
+
+	i.details = strings.Join(changes, "\n")
+
+	return true
+}
+
+func (*issueHardwareChanged) Spec() *spec {
+	return &spec{
+		Type:        TypeHardwareChanged,
+		Severity:    SeverityMajor,
+		Description: "hardware of the machine changed since its previous registration and the change was not acknowledged yet",
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
 	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
 	"github.com/stretchr/testify/require"
 )
@@ -490,6 +491,40 @@ func TestFindIssues(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "hardware changed",
+			only: []Type{TypeHardwareChanged},
+			machines: func() []*metal.Machine {
+				changed := machineTemplate("changed")
+				changed.HardwareChanges = []metal.HardwareChange{
+					{Component: metal.HardwareComponentMemory, Message: "memory changed from 275 GB to 258 GB"},
+					{Component: metal.HardwareComponentDisk, Message: "disk /dev/sdb (1.0 TB) was removed"},
+				}
+
+				return []*metal.Machine{
+					changed,
+					machineTemplate("good"),
+				}
+			},
+			eventContainers: func() []*metal.ProvisioningEventContainer {
+				return []*metal.ProvisioningEventContainer{
+					eventContainerTemplate("changed"),
+					eventContainerTemplate("good"),
+				}
+			},
+			want: func(machines []*metal.Machine) MachineIssues {
+				return MachineIssues{
+					{
+						Machine: machines[0],
+						Issues: Issues{
+							toIssue(&issueHardwareChanged{
+								details: "- memory changed from 275 GB to 258 GB\n- disk /dev/sdb (1.0 TB) was removed",
+							}),
+						},
+					},
+				}
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -527,3 +562,19 @@ func TestAllIssues(t *testing.T) {
 		}
 	}
 }
+
+func TestToAPIV2Type(t *testing.T) {
+	for _, ty := range AllIssueTypes() {
+		apiType, err := ToAPIV2Type(ty)
+		if ty == TypeHardwareChanged {
+			require.ErrorIs(t, err, ErrNoAPIV2Type)
+			continue
+		}
+		require.NoError(t, err)
+		require.NotEqual(t, apiv2.MachineIssueType_MACHINE_ISSUE_TYPE_UNSPECIFIED, apiType, "issue type %s", ty)
+
+		back, err := FromAPIV2Type(apiType)
+		require.NoError(t, err)
+		require.Equal(t, ty, back)
+	}
+}
@@ -1,6 +1,7 @@
 package issues
 
 import (
+	"errors"
 	"fmt"
 
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
@@ -10,6 +11,9 @@ type (
 	Type string
 )
 
+// ErrNoAPIV2Type is returned for issue types which the api does not define a type for yet.
+var ErrNoAPIV2Type = errors.New("issue type is not defined in the api")
+
 func AllIssueTypes() []Type {
 	return []Type{
 		TypeNoPartition,
@@ -25,6 +29,7 @@ func AllIssueTypes() []Type {
 		TypeASNUniqueness,
 		TypeNonDistinctBMCIP,
 		TypeNoEventContainer,
+		TypeHardwareChanged,
 	}
 }
 
@@ -56,6 +61,9 @@ func ToAPIV2Type(issueType Type) (apiv2.MachineIssueType, error) {
 		return apiv2.MachineIssueType_MACHINE_ISSUE_TYPE_NO_PARTITION, nil
 	case TypeNonDistinctBMCIP:
 		return apiv2.MachineIssueType_MACHINE_ISSUE_TYPE_BMC_NON_DISTINCT_IP, nil
+	case TypeHardwareChanged:
+		// TODO: map to the hardware changed issue type once the api defines it.
+		return apiv2.MachineIssueType_MACHINE_ISSUE_TYPE_UNSPECIFIED, fmt.Errorf("%w: %s", ErrNoAPIV2Type, issueType)
 	}
 	return apiv2.MachineIssueType_MACHINE_ISSUE_TYPE_UNSPECIFIED, fmt.Errorf("unknown issue type: %s", issueType)
 }
@@ -122,6 +130,8 @@ func NewIssueFromType(t Type) (issue, error) {
 		return &issueNonDistinctBMCIP{}, nil
 	case TypeNoEventContainer:
 		return &issueNoEventContainer{}, nil
+	case TypeHardwareChanged:
+		return &issueHardwareChanged{}, nil
 	default:
 		return nil, fmt.Errorf("unknown issue type: %s", t)
 	}
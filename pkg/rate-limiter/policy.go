@@ -0,0 +1,148 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const defaultGroup = "default"
+
+type (
+	// Limit describes a token bucket which is refilled with RequestsPerMinute tokens per minute
+	// and holds at most Burst tokens. If Burst is zero, it defaults to RequestsPerMinute.
+	// A zero RequestsPerMinute disables the limit.
+	Limit struct {
+		RequestsPerMinute int `json:"requests_per_minute" yaml:"requests_per_minute"`
+		Burst             int `json:"burst,omitempty" yaml:"burst,omitempty"`
+	}
+
+	// Group applies limits to a set of procedures. Procedures are given by their full name,
+	// e.g. /metalstack.api.v2.MachineService/Create, a trailing * matches all procedures with
+	// the given prefix, e.g. /metalstack.api.v2.MachineService/*.
+	//
+	// The token limit is accounted per api token, the tenant limit is shared by all requests
+	// which target the same tenant.
+	Group struct {
+		Name       string   `json:"name" yaml:"name"`
+		Procedures []string `json:"procedures" yaml:"procedures"`
+		Token      Limit    `json:"token" yaml:"token"`
+		Tenant     Limit    `json:"tenant" yaml:"tenant"`
+	}
+
+	// Policy contains the procedure groups, procedures which are not part of any group are
+	// accounted in the default group.
+	Policy struct {
+		Default Group   `json:"default" yaml:"default"`
+		Groups  []Group `json:"groups" yaml:"groups"`
+	}
+)
+
+// LoadPolicy reads a policy from the given yaml file.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rate-limit policy: %w", err)
+	}
+
+	var policy Policy
+	err = yaml.Unmarshal(raw, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse rate-limit policy: %w", err)
+	}
+
+	err = policy.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// Validate checks the policy for consistency.
+func (p *Policy) Validate() error {
+	names := map[string]bool{defaultGroup: true}
+
+	for _, g := range append([]Group{p.Default}, p.Groups...) {
+		if err := g.Token.validate(); err != nil {
+			return fmt.Errorf("token limit of group %q is invalid: %w", g.Name, err)
+		}
+		if err := g.Tenant.validate(); err != nil {
+			return fmt.Errorf("tenant limit of group %q is invalid: %w", g.Name, err)
+		}
+	}
+
+	for _, g := range p.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("rate-limit group name must not be empty")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("rate-limit group %q is defined more than once", g.Name)
+		}
+		names[g.Name] = true
+
+		if len(g.Procedures) == 0 {
+			return fmt.Errorf("rate-limit group %q does not contain any procedures", g.Name)
+		}
+	}
+
+	return nil
+}
+
+// group returns the group which is responsible for the given procedure.
+// Exact matches take precedence over prefix matches, the longest prefix wins.
+func (p *Policy) group(procedure string) *Group {
+	var (
+		match     *Group
+		matchSize int
+	)
+
+	for i := range p.Groups {
+		g := &p.Groups[i]
+
+		for _, pattern := range g.Procedures {
+			if pattern == procedure {
+				return g
+			}
+
+			prefix, ok := strings.CutSuffix(pattern, "*")
+			if !ok || !strings.HasPrefix(procedure, prefix) {
+				continue
+			}
+
+			if match == nil || len(prefix) > matchSize {
+				match = g
+				matchSize = len(prefix)
+			}
+		}
+	}
+
+	if match != nil {
+		return match
+	}
+
+	return &p.Default
+}
+
+func (l Limit) validate() error {
+	if l.RequestsPerMinute < 0 {
+		return fmt.Errorf("requests per minute must not be negative")
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+func (l Limit) enabled() bool {
+	return l.RequestsPerMinute > 0
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.RequestsPerMinute
+}
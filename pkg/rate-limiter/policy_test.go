@@ -0,0 +1,163 @@
+package ratelimiter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_group(t *testing.T) {
+	policy := &Policy{
+		Default: Group{Name: defaultGroup},
+		Groups: []Group{
+			{
+				Name:       "machine",
+				Procedures: []string{"/metalstack.api.v2.MachineService/*"},
+			},
+			{
+				Name:       "machine-expensive",
+				Procedures: []string{"/metalstack.api.v2.MachineService/Create", "/metalstack.api.v2.MachineService/List"},
+			},
+			{
+				Name:       "api",
+				Procedures: []string{"/metalstack.api.v2.*"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		procedure string
+		want      string
+	}{
+		{
+			name:      "exact match",
+			procedure: "/metalstack.api.v2.MachineService/Create",
+			want:      "machine-expensive",
+		},
+		{
+			name:      "longest prefix wins",
+			procedure: "/metalstack.api.v2.MachineService/Get",
+			want:      "machine",
+		},
+		{
+			name:      "shorter prefix",
+			procedure: "/metalstack.api.v2.IPService/Get",
+			want:      "api",
+		},
+		{
+			name:      "default",
+			procedure: "/metalstack.admin.v2.MachineService/Get",
+			want:      defaultGroup,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.group(tt.procedure)
+			require.Equal(t, tt.want, got.Name)
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Policy
+		wantErr string
+	}{
+		{
+			name: "valid policy",
+			content: `
+default:
+  token:
+    requests_per_minute: 100
+  tenant:
+    requests_per_minute: 500
+    burst: 100
+groups:
+  - name: machine-expensive
+    procedures:
+      - /metalstack.api.v2.MachineService/Create
+    token:
+      requests_per_minute: 10
+      burst: 2
+    tenant:
+      requests_per_minute: 30
+`,
+			want: &Policy{
+				Default: Group{
+					Token:  Limit{RequestsPerMinute: 100},
+					Tenant: Limit{RequestsPerMinute: 500, Burst: 100},
+				},
+				Groups: []Group{
+					{
+						Name:       "machine-expensive",
+						Procedures: []string{"/metalstack.api.v2.MachineService/Create"},
+						Token:      Limit{RequestsPerMinute: 10, Burst: 2},
+						Tenant:     Limit{RequestsPerMinute: 30},
+					},
+				},
+			},
+		},
+		{
+			name: "group without procedures",
+			content: `
+groups:
+  - name: empty
+`,
+			wantErr: `rate-limit group "empty" does not contain any procedures`,
+		},
+		{
+			name: "duplicate group",
+			content: `
+groups:
+  - name: a
+    procedures: [/a]
+  - name: a
+    procedures: [/b]
+`,
+			wantErr: `rate-limit group "a" is defined more than once`,
+		},
+		{
+			name: "default group name is reserved",
+			content: `
+groups:
+  - name: default
+    procedures: [/a]
+`,
+			wantErr: `rate-limit group "default" is defined more than once`,
+		},
+		{
+			name: "negative limit",
+			content: `
+groups:
+  - name: a
+    procedures: [/a]
+    tenant:
+      requests_per_minute: -1
+`,
+			wantErr: `tenant limit of group "a" is invalid: requests per minute must not be negative`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+			got, err := LoadPolicy(path)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("LoadPolicy() diff = %s", diff)
+			}
+		})
+	}
+}
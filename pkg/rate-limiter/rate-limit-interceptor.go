@@ -5,11 +5,16 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
+	"time"
 
 	"connectrpc.com/connect"
 	"github.com/metal-stack/api/go/errorutil"
+	"github.com/metal-stack/api/go/permissions"
 	"github.com/metal-stack/metal-apiserver/pkg/token"
+	"github.com/metal-stack/metal-lib/pkg/cache"
 
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -19,20 +24,50 @@ type Config struct {
 
 	MaxRequestsPerMinuteToken           int
 	MaxRequestsPerMinuteUnauthenticated int
+
+	// Policy defines limits per procedure group, if nil, all procedures are accounted
+	// in a default group which is limited by MaxRequestsPerMinuteToken.
+	Policy *Policy
+	// ProjectTenant resolves the tenant of a project, it is used to account requests which target
+	// a project to the tenant limit. If nil, only requests which directly target a tenant are accounted.
+	ProjectTenant func(ctx context.Context, projectID string) (string, error)
 }
 
 type ratelimitInterceptor struct {
 	ratelimiter                         *ratelimiter
-	maxRequestsPerMinuteToken           int
+	policy                              *Policy
 	maxRequestsPerMinuteUnauthenticated int
+	projectTenantCache                  *cache.Cache[string, string]
 	log                                 *slog.Logger
 }
 
 func NewInterceptor(c *Config) *ratelimitInterceptor {
+	policy := &Policy{
+		Default: Group{
+			Token: Limit{RequestsPerMinute: c.MaxRequestsPerMinuteToken},
+		},
+	}
+	if c.Policy != nil {
+		// copy the policy, defaults must not be written into the configuration of the caller
+		policy = &Policy{
+			Default: c.Policy.Default,
+			Groups:  slices.Clone(c.Policy.Groups),
+		}
+	}
+	if policy.Default.Name == "" {
+		policy.Default.Name = defaultGroup
+	}
+
+	var projectTenantCache *cache.Cache[string, string]
+	if c.ProjectTenant != nil {
+		projectTenantCache = cache.New(1*time.Hour, c.ProjectTenant)
+	}
+
 	return &ratelimitInterceptor{
 		ratelimiter:                         New(c.RedisClient),
-		maxRequestsPerMinuteToken:           c.MaxRequestsPerMinuteToken,
+		policy:                              policy,
 		maxRequestsPerMinuteUnauthenticated: c.MaxRequestsPerMinuteUnauthenticated,
+		projectTenantCache:                  projectTenantCache,
 		log:                                 c.Log,
 	}
 }
@@ -46,7 +81,9 @@ func (i *ratelimitInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFu
 		)
 
 		if ok && t != nil {
-			_, err = i.ratelimiter.CheckLimitTokenAccess(ctx, t, i.maxRequestsPerMinuteToken)
+			group := i.policy.group(req.Spec().Procedure)
+
+			_, err = i.ratelimiter.CheckLimitTokenAccess(ctx, t, i.tenantFromRequest(ctx, req, t), group)
 		} else {
 			clientIP, ok := extractClientIP(req.Header())
 			if !ok {
@@ -54,12 +91,14 @@ func (i *ratelimitInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFu
 				return next(ctx, req)
 			}
 
-			_, err = i.ratelimiter.CheckLimitUnauthenticatedAccess(ctx, clientIP, i.maxRequestsPerMinuteUnauthenticated)
+			_, err = i.ratelimiter.CheckLimitUnauthenticatedAccess(ctx, clientIP, Limit{RequestsPerMinute: i.maxRequestsPerMinuteUnauthenticated})
 		}
 
 		if err != nil {
 			if ratelimiterError, ok := errors.AsType[*errRatelimitReached](err); ok {
-				return nil, errorutil.NewResourceExhausted(ratelimiterError)
+				connectErr := connect.NewError(connect.CodeResourceExhausted, ratelimiterError)
+				connectErr.Meta().Set("Retry-After", ratelimiterError.retryAfterSeconds())
+				return nil, connectErr
 			}
 			return nil, errorutil.NewInternal(err)
 		}
@@ -80,6 +119,27 @@ func (i *ratelimitInterceptor) WrapStreamingHandler(next connect.StreamingHandle
 	}
 }
 
+// tenantFromRequest returns the tenant which is charged for the request. This is the tenant the request
+// targets directly or the tenant of the targeted project. Requests which do not target a tenant
+// are charged to the tenant of the token owner.
+func (i *ratelimitInterceptor) tenantFromRequest(ctx context.Context, req connect.AnyRequest, t *apiv2.Token) string {
+	if tenantID, ok := permissions.GetTenantFromRequest(req); ok {
+		return tenantID
+	}
+
+	if projectID, ok := permissions.GetProjectFromRequest(req); ok && i.projectTenantCache != nil {
+		tenantID, err := i.projectTenantCache.Get(ctx, projectID)
+		if err == nil {
+			return tenantID
+		}
+
+		// the request will fail later on anyway if the project does not exist
+		i.log.Debug("unable to lookup tenant of project for rate-limiting, charging token owner", "project", projectID, "error", err)
+	}
+
+	return t.User
+}
+
 func extractClientIP(header http.Header) (string, bool) {
 	ip := header.Get("X-Forwarded-For")
 	if ip != "" {
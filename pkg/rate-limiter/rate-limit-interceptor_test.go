@@ -50,7 +50,7 @@ func Test_ratelimitInterceptor_WrapUnary(t *testing.T) {
 				ctx := token.ContextWithToken(t.Context(), &apiv2.Token{User: "u1", Uuid: "t1"})
 				return ctx, connect.NewRequest(&testMsg{})
 			},
-			calls:    7, // maxToken=5 allows 6 calls before rejection
+			calls:    6, // maxToken=5 allows 5 calls before rejection
 			wantErr:  true,
 			wantCode: connect.CodeResourceExhausted,
 			wantNext: false,
@@ -87,7 +87,7 @@ func Test_ratelimitInterceptor_WrapUnary(t *testing.T) {
 				req.Header().Set("X-Forwarded-For", "10.0.0.1")
 				return t.Context(), req
 			},
-			calls:    5, // maxUnauth=3 allows 4 calls before rejection
+			calls:    4, // maxUnauth=3 allows 3 calls before rejection
 			wantErr:  true,
 			wantCode: connect.CodeResourceExhausted,
 			wantNext: false,
@@ -110,12 +110,12 @@ func Test_ratelimitInterceptor_WrapUnary(t *testing.T) {
 
 			c := redis.NewClient(&redis.Options{Addr: s.Addr()})
 
-			interceptor := &ratelimitInterceptor{
-				ratelimiter:                         New(c),
-				maxRequestsPerMinuteToken:           tt.maxToken,
-				maxRequestsPerMinuteUnauthenticated: tt.maxUnauth,
-				log:                                 slog.Default(),
-			}
+			interceptor := NewInterceptor(&Config{
+				Log:                                 slog.Default(),
+				RedisClient:                         c,
+				MaxRequestsPerMinuteToken:           tt.maxToken,
+				MaxRequestsPerMinuteUnauthenticated: tt.maxUnauth,
+			})
 
 			var nextCallCount int
 			wrapped := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
@@ -138,6 +138,7 @@ func Test_ratelimitInterceptor_WrapUnary(t *testing.T) {
 				var connectErr *connect.Error
 				require.ErrorAs(t, err, &connectErr)
 				require.Equal(t, tt.wantCode, connectErr.Code())
+				require.NotEmpty(t, connectErr.Meta().Get("Retry-After"))
 			} else {
 				require.NoError(t, err)
 			}
@@ -150,3 +151,14 @@ func Test_ratelimitInterceptor_WrapUnary(t *testing.T) {
 		})
 	}
 }
+
+func TestNewInterceptor_DoesNotModifyPolicy(t *testing.T) {
+	policy := &Policy{
+		Groups: []Group{{Name: "create", Procedures: []string{"/metalstack.api.v2.MachineService/Create"}}},
+	}
+
+	interceptor := NewInterceptor(&Config{Log: slog.Default(), Policy: policy})
+
+	require.Equal(t, defaultGroup, interceptor.policy.Default.Name)
+	require.Empty(t, policy.Default.Name)
+}
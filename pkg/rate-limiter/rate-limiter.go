@@ -2,7 +2,6 @@ package ratelimiter
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -16,85 +15,186 @@ import (
 const (
 	separator = ":"
 	prefix    = "ratelimiter_"
-	halfHour  = 30 * time.Minute
 )
 
+// takeScript implements a token bucket for every given key. A request is only allowed if every bucket
+// contains at least one token, in this case one token is taken from every bucket. Otherwise nothing
+// is taken and the time in milliseconds until the request would be allowed is returned along with
+// the index of the bucket which has to be waited for.
+//
+// KEYS: the bucket keys
+// ARGV[1]: the current time in unix milliseconds
+// ARGV[2n], ARGV[2n+1]: requests per minute and burst of the n-th bucket
+var takeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local buckets = {}
+local wait = 0
+local exhausted = 0
+
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2]) / 60000
+	local burst = tonumber(ARGV[i * 2 + 1])
+	local state = redis.call("HMGET", key, "tokens", "ts")
+	local tokens = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+
+	tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+	if tokens < 1 then
+		local w = math.ceil((1 - tokens) / rate)
+		if w > wait then
+			wait = w
+			exhausted = i
+		end
+	end
+
+	buckets[i] = {tokens = tokens, rate = rate, burst = burst}
+end
+
+if wait > 0 then
+	return {0, wait, exhausted}
+end
+
+for i, key in ipairs(KEYS) do
+	local b = buckets[i]
+	redis.call("HSET", key, "tokens", tostring(b.tokens - 1), "ts", tostring(now))
+	redis.call("PEXPIRE", key, math.ceil(b.burst / b.rate) + 1000)
+end
+
+return {1, 0, 0}
+`)
+
 type (
 	errRatelimitReached struct {
-		limit int
+		scope      string
+		limit      int
+		retryAfter time.Duration
 	}
 
 	ratelimiter struct {
 		client *redis.Client
+		now    func() time.Time
+	}
+
+	bucket struct {
+		scope string
+		key   string
+		limit Limit
 	}
 )
 
 func New(client *redis.Client) *ratelimiter {
 	return &ratelimiter{
 		client: client,
+		now:    time.Now,
 	}
 }
 
-// CheckLimitTokenAccess enforces maxRequestsPerMinute for the given token
-func (r *ratelimiter) CheckLimitTokenAccess(ctx context.Context, t *apiv2.Token, maxRequestsPerMinute int) (bool, error) {
+// CheckLimitTokenAccess enforces the limits of the given group for the given token and the tenant the request targets.
+// The tenant may be empty in which case only the token limit is enforced.
+func (r *ratelimiter) CheckLimitTokenAccess(ctx context.Context, t *apiv2.Token, tenant string, group *Group) (bool, error) {
 	if token.IsAdminToken(t) {
 		// admin tokens should not have a rate-limit (i.e. the accounting uses the api excessively to report usages)
 		return true, nil
 	}
 
-	return r.limit(ctx, keyFromToken(t), maxRequestsPerMinute)
+	buckets := []bucket{
+		{
+			scope: "token",
+			key:   keyFromToken(group.Name, t),
+			limit: group.Token,
+		},
+	}
+
+	if tenant != "" {
+		buckets = append(buckets, bucket{
+			scope: "tenant " + tenant,
+			key:   keyFromTenant(group.Name, tenant),
+			limit: group.Tenant,
+		})
+	}
+
+	return r.take(ctx, buckets...)
 }
 
-// CheckLimitTokenAccess enforces maxRequestsPerMinute for the given token
-func (r *ratelimiter) CheckLimitUnauthenticatedAccess(ctx context.Context, ip string, maxRequestsPerMinute int) (bool, error) {
-	return r.limit(ctx, keyFromIP(ip), maxRequestsPerMinute)
+// CheckLimitUnauthenticatedAccess enforces the given limit for the given client ip
+func (r *ratelimiter) CheckLimitUnauthenticatedAccess(ctx context.Context, ip string, limit Limit) (bool, error) {
+	return r.take(ctx, bucket{
+		scope: "client " + ip,
+		key:   keyFromIP(ip),
+		limit: limit,
+	})
 }
 
-func (r *ratelimiter) limit(ctx context.Context, k string, maxRequestsPerMinute int) (bool, error) {
-	raw, err := r.client.Get(ctx, k).Result()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		return false, err
-	}
+func (r *ratelimiter) take(ctx context.Context, buckets ...bucket) (bool, error) {
+	var (
+		keys []string
+		args = []any{r.now().UnixMilli()}
+		used []bucket
+	)
 
-	if err == nil {
-		count, err := strconv.Atoi(raw)
-		if err != nil {
-			return false, fmt.Errorf("limit count is malformed: %w", err)
+	for _, b := range buckets {
+		if !b.limit.enabled() {
+			continue
 		}
 
-		if count > maxRequestsPerMinute {
-			return false, &errRatelimitReached{limit: maxRequestsPerMinute}
-		}
+		keys = append(keys, b.key)
+		args = append(args, b.limit.RequestsPerMinute, b.limit.burst())
+		used = append(used, b)
 	}
 
-	// Redis Pipeline will create a new key prefix every minute
-	pipe := r.client.TxPipeline()
-
-	_ = pipe.Incr(ctx, k)
-	_ = pipe.Expire(ctx, k, halfHour) // expiration must be less than 60 minutes
+	if len(keys) == 0 {
+		return true, nil
+	}
 
-	_, err = pipe.Exec(ctx)
+	res, err := takeScript.Run(ctx, r.client, keys, args...).Int64Slice()
 	if err != nil {
-		return false, fmt.Errorf("unable to increment rate-limit count: %w", err)
+		return false, fmt.Errorf("unable to take rate-limit token: %w", err)
 	}
 
-	return true, nil
+	if len(res) != 3 {
+		return false, fmt.Errorf("unexpected rate-limit script result: %v", res)
+	}
+
+	if res[0] == 1 {
+		return true, nil
+	}
+
+	exhausted := used[0]
+	if idx := int(res[2]) - 1; idx >= 0 && idx < len(used) {
+		exhausted = used[idx]
+	}
+
+	return false, &errRatelimitReached{
+		scope:      exhausted.scope,
+		limit:      exhausted.limit.RequestsPerMinute,
+		retryAfter: time.Duration(res[1]) * time.Millisecond,
+	}
+}
+
+func keyFromToken(group string, t *apiv2.Token) string {
+	return prefix + "token" + separator + group + separator + t.User + separator + t.Uuid
 }
 
-func keyFromToken(t *apiv2.Token) string {
-	return prefix + t.User + separator + t.Uuid + separator + strconv.Itoa(time.Now().Minute())
+func keyFromTenant(group, tenant string) string {
+	return prefix + "tenant" + separator + group + separator + tenant
 }
 
 func keyFromIP(ip string) string {
-	return prefix + ip + separator + strconv.Itoa(time.Now().Minute())
+	return prefix + "ip" + separator + ip
 }
 
 // Error implements the error interface
 func (e *errRatelimitReached) Error() string {
-	return fmt.Sprintf("you have reached the per-minute API rate limit (limit: %d)", e.limit)
+	return fmt.Sprintf("you have reached the API rate limit for %s (limit: %d per minute), retry after %s", e.scope, e.limit, e.retryAfter)
 }
 
 // Unwrap implements the errorsas interface
 func (e *errRatelimitReached) Unwrap() error {
 	return nil
 }
+
+// retryAfterSeconds returns the value for the Retry-After header, which only supports full seconds.
+func (e *errRatelimitReached) retryAfterSeconds() string {
+	return strconv.Itoa(int((e.retryAfter + time.Second - 1) / time.Second))
+}
@@ -20,10 +20,14 @@ func Test_ratelimiter_CheckLimitTokenAccess(t *testing.T) {
 	s := miniredis.RunT(t)
 	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
 
+	now := time.Now()
 	limiter := ratelimiter{
 		client: c,
+		now:    func() time.Time { return now },
 	}
 
+	group := &Group{Name: defaultGroup, Token: Limit{RequestsPerMinute: 20}}
+
 	privateKey, err := certs.NewRedisStore(&certs.Config{
 		RedisClient: c,
 	}).LatestPrivate(ctx)
@@ -32,14 +36,101 @@ func Test_ratelimiter_CheckLimitTokenAccess(t *testing.T) {
 	_, tok, err := token.NewJWT(apiv2.TokenType_TOKEN_TYPE_USER, "userid", "issuer", 30*time.Minute, privateKey)
 	require.NoError(t, err)
 
-	for i := 0; i <= 20; i++ {
-		allowed, err := limiter.CheckLimitTokenAccess(ctx, tok, 20)
+	for range 20 {
+		allowed, err := limiter.CheckLimitTokenAccess(ctx, tok, "", group)
 		require.NoError(t, err)
 		assert.True(t, allowed)
 	}
 
-	allowed, err := limiter.CheckLimitTokenAccess(ctx, tok, 20)
+	allowed, err := limiter.CheckLimitTokenAccess(ctx, tok, "", group)
 	require.Error(t, err)
-	require.ErrorContains(t, err, "you have reached the per-minute API rate limit (limit: 20)")
+	require.ErrorContains(t, err, "you have reached the API rate limit for token (limit: 20 per minute), retry after 3s")
 	assert.False(t, allowed)
+
+	// one token is refilled every three seconds
+	now = now.Add(3 * time.Second)
+
+	allowed, err = limiter.CheckLimitTokenAccess(ctx, tok, "", group)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = limiter.CheckLimitTokenAccess(ctx, tok, "", group)
+	require.Error(t, err)
+	assert.False(t, allowed)
+}
+
+func Test_ratelimiter_CheckLimitTokenAccessTenant(t *testing.T) {
+	ctx := t.Context()
+	s := miniredis.RunT(t)
+	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	now := time.Now()
+	limiter := ratelimiter{
+		client: c,
+		now:    func() time.Time { return now },
+	}
+
+	var (
+		group = &Group{
+			Name:   "machine-create",
+			Token:  Limit{RequestsPerMinute: 10},
+			Tenant: Limit{RequestsPerMinute: 60, Burst: 3},
+		}
+		otherGroup = &Group{
+			Name:   defaultGroup,
+			Token:  Limit{RequestsPerMinute: 10},
+			Tenant: Limit{RequestsPerMinute: 60, Burst: 3},
+		}
+		ci  = &apiv2.Token{User: "ci", Uuid: "t1"}
+		dev = &apiv2.Token{User: "dev", Uuid: "t2"}
+	)
+
+	for range 3 {
+		allowed, err := limiter.CheckLimitTokenAccess(ctx, ci, "tenant-a", group)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	// tenant bucket is shared by all tokens
+	allowed, err := limiter.CheckLimitTokenAccess(ctx, dev, "tenant-a", group)
+	require.ErrorContains(t, err, "you have reached the API rate limit for tenant tenant-a (limit: 60 per minute), retry after 1s")
+	assert.False(t, allowed)
+
+	// other tenants are not affected
+	allowed, err = limiter.CheckLimitTokenAccess(ctx, dev, "tenant-b", group)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// other groups are not affected
+	allowed, err = limiter.CheckLimitTokenAccess(ctx, ci, "tenant-a", otherGroup)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// a denied request must not consume tokens of the token bucket, so after the tenant bucket
+	// refilled one request must succeed
+	now = now.Add(time.Second)
+
+	allowed, err = limiter.CheckLimitTokenAccess(ctx, dev, "tenant-a", group)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func Test_ratelimiter_CheckLimitTokenAccessAdmin(t *testing.T) {
+	ctx := t.Context()
+	s := miniredis.RunT(t)
+	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	limiter := New(c)
+
+	var (
+		adminRole = apiv2.AdminRole_ADMIN_ROLE_VIEWER
+		tok       = &apiv2.Token{User: "accounting", Uuid: "t1", AdminRole: &adminRole}
+		group     = &Group{Name: defaultGroup, Token: Limit{RequestsPerMinute: 1}, Tenant: Limit{RequestsPerMinute: 1}}
+	)
+
+	for range 10 {
+		allowed, err := limiter.CheckLimitTokenAccess(ctx, tok, "tenant-a", group)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
 }
@@ -0,0 +1,173 @@
+package redact
+
+import (
+	"strings"
+
+	"github.com/metal-stack/metal-lib/auditing"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Mask is the value which replaces the content of a redacted field.
+const Mask = "***"
+
+// DefaultFields are the proto field names which contain secrets in the metal-stack api
+// and are therefore redacted if no other fields are configured.
+var DefaultFields = []string{
+	// bmc passwords in MachineBMC, e.g. in WaitForBMCCommandResponse or GetBMCResponse
+	"password",
+	// token secrets in token create and refresh responses as well as invite secrets
+	"secret",
+	// vpn auth keys in VPNServiceAuthKeyResponse and MachineVPN
+	"auth_key",
+	// userdata of machine allocations
+	"userdata",
+}
+
+type (
+	// Redactor masks fields of proto messages which contain secrets,
+	// such that they can be safely logged or sent to audit backends.
+	Redactor struct {
+		names     map[protoreflect.Name]bool
+		fullNames map[protoreflect.FullName]bool
+	}
+
+	auditingBackend struct {
+		auditing.Auditing
+		r *Redactor
+	}
+)
+
+// New returns a redactor for the given fields. A field can either be given by its proto name (e.g. "password"),
+// which redacts the field in every message, or by its fully qualified name (e.g. "metalstack.api.v2.MachineBMC.password").
+func New(fields ...string) *Redactor {
+	r := &Redactor{
+		names:     map[protoreflect.Name]bool{},
+		fullNames: map[protoreflect.FullName]bool{},
+	}
+
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		if strings.Contains(f, ".") {
+			r.fullNames[protoreflect.FullName(f)] = true
+			continue
+		}
+
+		r.names[protoreflect.Name(f)] = true
+	}
+
+	return r
+}
+
+// Redact returns a copy of the given value with all configured fields masked.
+// Values which are not proto messages are returned unchanged.
+func (r *Redactor) Redact(v any) any {
+	if r == nil {
+		return v
+	}
+
+	msg, ok := v.(proto.Message)
+	if !ok || msg == nil {
+		return v
+	}
+
+	if !msg.ProtoReflect().IsValid() {
+		return v
+	}
+
+	redacted := proto.Clone(msg)
+	r.redactMessage(redacted.ProtoReflect())
+
+	return redacted
+}
+
+func (r *Redactor) redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if r.matches(fd) {
+			r.mask(m, fd, v)
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := range list.Len() {
+				r.redactMessage(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				r.redactMessage(mv.Message())
+				return true
+			})
+		case fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			r.redactMessage(v.Message())
+		}
+
+		return true
+	})
+}
+
+func (r *Redactor) matches(fd protoreflect.FieldDescriptor) bool {
+	return r.names[fd.Name()] || r.fullNames[fd.FullName()]
+}
+
+func (r *Redactor) mask(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case fd.IsList():
+		if fd.Kind() != protoreflect.StringKind && fd.Kind() != protoreflect.BytesKind {
+			m.Clear(fd)
+			return
+		}
+
+		list := v.List()
+		for i := range list.Len() {
+			list.Set(i, maskedValue(fd.Kind()))
+		}
+	case fd.IsMap():
+		if fd.MapValue().Kind() != protoreflect.StringKind && fd.MapValue().Kind() != protoreflect.BytesKind {
+			m.Clear(fd)
+			return
+		}
+
+		mp := v.Map()
+		mp.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			mp.Set(k, maskedValue(fd.MapValue().Kind()))
+			return true
+		})
+	case fd.Kind() == protoreflect.StringKind, fd.Kind() == protoreflect.BytesKind:
+		m.Set(fd, maskedValue(fd.Kind()))
+	default:
+		// fields which cannot hold the mask are removed entirely
+		m.Clear(fd)
+	}
+}
+
+func maskedValue(kind protoreflect.Kind) protoreflect.Value {
+	if kind == protoreflect.BytesKind {
+		return protoreflect.ValueOfBytes([]byte(Mask))
+	}
+
+	return protoreflect.ValueOfString(Mask)
+}
+
+// NewAuditing wraps the given audit backend such that the bodies of all indexed entries are redacted.
+func NewAuditing(backend auditing.Auditing, r *Redactor) auditing.Auditing {
+	if backend == nil {
+		return nil
+	}
+
+	return &auditingBackend{
+		Auditing: backend,
+		r:        r,
+	}
+}
+
+func (a *auditingBackend) Index(entry auditing.Entry) error {
+	entry.Body = a.r.Redact(entry.Body)
+
+	return a.Auditing.Index(entry)
+}
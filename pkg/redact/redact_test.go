@@ -0,0 +1,211 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	infrav2 "github.com/metal-stack/api/go/metalstack/infra/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		in     any
+		want   any
+	}{
+		{
+			name:   "bmc password of wait for bmc command",
+			fields: DefaultFields,
+			in: &infrav2.WaitForBMCCommandResponse{
+				Uuid:       "m1",
+				BmcCommand: apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_ON,
+				MachineBmc: &apiv2.MachineBMC{
+					Address:  "10.0.0.1:623",
+					User:     "metal",
+					Password: "secret-bmc-password",
+				},
+			},
+			want: &infrav2.WaitForBMCCommandResponse{
+				Uuid:       "m1",
+				BmcCommand: apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_ON,
+				MachineBmc: &apiv2.MachineBMC{
+					Address:  "10.0.0.1:623",
+					User:     "metal",
+					Password: Mask,
+				},
+			},
+		},
+		{
+			name:   "console password",
+			fields: DefaultFields,
+			in: &adminv2.MachineServiceConsolePasswordResponse{
+				Uuid:     "m1",
+				Password: "console-password",
+			},
+			want: &adminv2.MachineServiceConsolePasswordResponse{
+				Uuid:     "m1",
+				Password: Mask,
+			},
+		},
+		{
+			name:   "vpn auth key",
+			fields: DefaultFields,
+			in: &adminv2.VPNServiceAuthKeyResponse{
+				Address:   "https://headscale",
+				AuthKey:   "auth-key",
+				Ephemeral: true,
+			},
+			want: &adminv2.VPNServiceAuthKeyResponse{
+				Address:   "https://headscale",
+				AuthKey:   Mask,
+				Ephemeral: true,
+			},
+		},
+		{
+			name:   "userdata and vpn auth key of a machine",
+			fields: DefaultFields,
+			in: &apiv2.MachineServiceGetResponse{
+				Machine: &apiv2.Machine{
+					Uuid: "m1",
+					Allocation: &apiv2.MachineAllocation{
+						Name:     "m1",
+						Userdata: "#cloud-config",
+						Vpn: &apiv2.MachineVPN{
+							ControlPlaneAddress: "https://headscale",
+							AuthKey:             "auth-key",
+						},
+					},
+				},
+			},
+			want: &apiv2.MachineServiceGetResponse{
+				Machine: &apiv2.Machine{
+					Uuid: "m1",
+					Allocation: &apiv2.MachineAllocation{
+						Name:     "m1",
+						Userdata: Mask,
+						Vpn: &apiv2.MachineVPN{
+							ControlPlaneAddress: "https://headscale",
+							AuthKey:             Mask,
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "userdata of a machine create request",
+			fields: DefaultFields,
+			in: &apiv2.MachineServiceCreateRequest{
+				Project:  "p1",
+				Userdata: new("#cloud-config"),
+			},
+			want: &apiv2.MachineServiceCreateRequest{
+				Project:  "p1",
+				Userdata: new(Mask),
+			},
+		},
+		{
+			name:   "token secret of token create",
+			fields: DefaultFields,
+			in: &apiv2.TokenServiceCreateResponse{
+				Token:  &apiv2.Token{Uuid: "t1", User: "u1"},
+				Secret: "jwt",
+			},
+			want: &apiv2.TokenServiceCreateResponse{
+				Token:  &apiv2.Token{Uuid: "t1", User: "u1"},
+				Secret: Mask,
+			},
+		},
+		{
+			name:   "token secret of admin token create",
+			fields: DefaultFields,
+			in: &adminv2.TokenServiceCreateResponse{
+				Token:  &apiv2.Token{Uuid: "t1", User: "u1"},
+				Secret: "jwt",
+			},
+			want: &adminv2.TokenServiceCreateResponse{
+				Token:  &apiv2.Token{Uuid: "t1", User: "u1"},
+				Secret: Mask,
+			},
+		},
+		{
+			name:   "token secret of token refresh",
+			fields: DefaultFields,
+			in: &apiv2.TokenServiceRefreshResponse{
+				Token:  &apiv2.Token{Uuid: "t1", User: "u1"},
+				Secret: "jwt",
+			},
+			want: &apiv2.TokenServiceRefreshResponse{
+				Token:  &apiv2.Token{Uuid: "t1", User: "u1"},
+				Secret: Mask,
+			},
+		},
+		{
+			name:   "project invite secret",
+			fields: DefaultFields,
+			in: &apiv2.ProjectServiceInviteResponse{
+				Invite: &apiv2.ProjectInvite{Project: "p1", Secret: "invite-secret"},
+			},
+			want: &apiv2.ProjectServiceInviteResponse{
+				Invite: &apiv2.ProjectInvite{Project: "p1", Secret: Mask},
+			},
+		},
+		{
+			name:   "tenant invite secret",
+			fields: DefaultFields,
+			in: &apiv2.TenantServiceInviteResponse{
+				Invite: &apiv2.TenantInvite{TargetTenant: "t1", Secret: "invite-secret"},
+			},
+			want: &apiv2.TenantServiceInviteResponse{
+				Invite: &apiv2.TenantInvite{TargetTenant: "t1", Secret: Mask},
+			},
+		},
+		{
+			name:   "fully qualified field name only redacts this field",
+			fields: []string{"metalstack.api.v2.MachineBMC.password"},
+			in: &infrav2.WaitForBMCCommandResponse{
+				MachineBmc: &apiv2.MachineBMC{Password: "secret-bmc-password"},
+			},
+			want: &infrav2.WaitForBMCCommandResponse{
+				MachineBmc: &apiv2.MachineBMC{Password: Mask},
+			},
+		},
+		{
+			name:   "no fields configured",
+			fields: nil,
+			in: &adminv2.MachineServiceConsolePasswordResponse{
+				Password: "console-password",
+			},
+			want: &adminv2.MachineServiceConsolePasswordResponse{
+				Password: "console-password",
+			},
+		},
+		{
+			name:   "non proto values are returned unchanged",
+			fields: DefaultFields,
+			in:     map[string]string{"password": "abc"},
+			want:   map[string]string{"password": "abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var original any = tt.in
+			if msg, ok := tt.in.(proto.Message); ok {
+				original = proto.Clone(msg)
+			}
+
+			got := New(tt.fields...).Redact(tt.in)
+			if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("diff = %s", diff)
+			}
+
+			if diff := cmp.Diff(original, tt.in, protocmp.Transform()); diff != "" {
+				t.Errorf("original value was modified, diff = %s", diff)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/metal-stack/api/go/errorutil"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
@@ -31,6 +32,22 @@ type (
 	}
 )
 
+// auditEvent indexes an action which was not caused by an api request, e.g. an action taken by a task, in all audit backends.
+// Auditing is best effort, the action was already taken and must not fail because of it.
+func (s *Store) auditEvent(entry auditing.Entry) {
+	entry.RequestId = uuid.NewString()
+	entry.Timestamp = time.Now()
+	entry.Component = api.AuditingComponent
+	entry.Type = auditing.EntryTypeEvent
+	entry.Phase = auditing.EntryPhaseSingle
+
+	for _, backend := range s.auditBackends {
+		if err := backend.Index(entry); err != nil {
+			s.log.Error("unable to index event in audit backend", "path", entry.Path, "error", err)
+		}
+	}
+}
+
 func (a *auditRepository) list(ctx context.Context, query *apiv2.AuditQuery) ([]*auditEntity, error) {
 	var (
 		limit = auditingListLimit
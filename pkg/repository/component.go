@@ -2,20 +2,40 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"sort"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/metal-stack/api/go/enum"
 	"github.com/metal-stack/api/go/errorutil"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
+	"github.com/valkey-io/valkey-go"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// Every component is stored in a hash under {component}:<type>:<identifier>, which expires if the component
+// does not report within the configured expiration. The indexes below are maintained on every ping,
+// entries of expired components are removed from them when they are encountered.
+// All keys share the {component} hash tag, so they are located in the same slot and can be updated in one transaction.
 const (
-	componentPrefix = "component:"
+	componentPrefix = "{component}:"
+	// componentUuidIndexPrefix points from the uuid of the latest ping to the component key, it expires together with the component
+	componentUuidIndexPrefix = componentPrefix + "index:uuid:"
+	// componentTypeIndexPrefix and componentIdentifierIndexPrefix are sets of component keys
+	componentTypeIndexPrefix       = componentPrefix + "index:type:"
+	componentIdentifierIndexPrefix = componentPrefix + "index:identifier:"
+	// componentDeadlineIndex is a sorted set of all component keys, scored by the unix milliseconds after which
+	// the component is considered missing
+	componentDeadlineIndex = componentPrefix + "index:deadline"
+
+	componentFieldUuid = "uuid"
+	componentFieldData = "data"
+
+	// componentMissedPings is the number of pings a component may miss before it is considered missing
+	componentMissedPings = 2
 )
 
 type (
@@ -31,78 +51,118 @@ type (
 func (e *componentEntity) SetChanged(time time.Time) {
 }
 
-func id(typeString, identifier string) string {
-	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(typeString+":"+identifier)).String()
-}
-
-func (c *componentRepository) key(rq *apiv2.Component) (string, error) {
-	typeAsString, err := enum.GetStringValue(rq.Type)
+func componentKey(c *apiv2.Component) (string, error) {
+	typeAsString, err := enum.GetStringValue(c.Type)
 	if err != nil {
 		return "", err
 	}
 
-	key := componentPrefix + ":" + *typeAsString + ":" + id(*typeAsString, rq.Identifier)
-	return key, nil
+	return componentPrefix + *typeAsString + ":" + c.Identifier, nil
+}
+
+// componentKeyParts returns the type and identifier of a component key.
+func componentKeyParts(key string) (typeString, identifier string) {
+	typeString, identifier, _ = strings.Cut(strings.TrimPrefix(key, componentPrefix), ":")
+	return typeString, identifier
+}
+
+// componentDeadline returns the time after which a component is considered missing if it did not report again.
+func componentDeadline(c *apiv2.Component, expiration time.Duration) time.Time {
+	reportedAt := time.Now()
+	if c.ReportedAt != nil {
+		reportedAt = c.ReportedAt.AsTime()
+	}
+
+	if c.Interval == nil || c.Interval.AsDuration() <= 0 {
+		return reportedAt.Add(expiration)
+	}
+
+	return reportedAt.Add(componentMissedPings * c.Interval.AsDuration())
 }
 
 func (c *componentRepository) get(ctx context.Context, id string) (*componentEntity, error) {
-	keys, err := c.s.component.Do(ctx, c.s.component.B().Keys().Pattern(componentPrefix+"*").Build()).AsStrSlice()
+	key, err := c.s.component.Do(ctx, c.s.component.B().Get().Key(componentUuidIndexPrefix+id).Build()).ToString()
+	if valkey.IsValkeyNil(err) {
+		return nil, errorutil.NotFound("no component with uuid %s found", id)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var result *componentEntity
-	for _, key := range keys {
-		value, err := c.s.component.Do(ctx, c.s.component.B().Get().Key(key).Build()).AsBytes()
-		if err != nil {
-			return nil, err
-		}
-
-		var component apiv2.Component
-		err = json.Unmarshal(value, &component)
-		if err != nil {
-			return nil, err
-		}
-
-		if component.Uuid == id {
-			result = &componentEntity{Component: &component}
-			break
-		}
+	components, err := c.load(ctx, key)
+	if err != nil {
+		return nil, err
 	}
-	if result == nil {
+
+	// the latest ping of the component may have replaced the uuid in the meantime
+	if len(components) == 0 || components[0].Uuid != id {
 		return nil, errorutil.NotFound("no component with uuid %s found", id)
 	}
-	return result, nil
+
+	return components[0], nil
 }
 
 func (c *componentRepository) validateCreate(ctx context.Context, rq *api.ComponentServiceCreateRequest) error {
+	if rq.Expiration <= 0 {
+		return errorutil.InvalidArgument("component expiration must be positive")
+	}
 	return nil
 }
 
 func (c *componentRepository) create(ctx context.Context, rq *api.ComponentServiceCreateRequest) (*componentEntity, error) {
-	payload, err := json.Marshal(rq)
+	key, err := componentKey(rq.Component)
 	if err != nil {
-		return nil, fmt.Errorf("unable to marshal component create request: %w", err)
+		return nil, fmt.Errorf("unable to get component key: %w", err)
 	}
 
-	key, err := c.key(rq.Component)
+	data, err := protojson.Marshal(rq.Component)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get component key: %w", err)
+		return nil, fmt.Errorf("unable to marshal component: %w", err)
 	}
 
-	err = c.s.component.Do(ctx, c.s.component.B().Set().Key(key).Value(string(payload)).Ex(rq.Expiration).Build()).Error()
-	if err != nil {
-		return nil, fmt.Errorf("unable to store component: %w", err)
+	previousUuid, err := c.s.component.Do(ctx, c.s.component.B().Hget().Key(key).Field(componentFieldUuid).Build()).ToString()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		return nil, fmt.Errorf("unable to get previous component: %w", err)
+	}
+
+	var (
+		b             = c.s.component.B()
+		typeString, _ = componentKeyParts(key)
+		deadline      = componentDeadline(rq.Component, rq.Expiration)
+		cmds          = valkey.Commands{b.Multi().Build()}
+	)
+
+	if previousUuid != "" && previousUuid != rq.Uuid {
+		cmds = append(cmds, b.Del().Key(componentUuidIndexPrefix+previousUuid).Build())
 	}
-	return &componentEntity{Component: rq.Component}, err
+	if rq.Uuid != "" {
+		cmds = append(cmds, b.Set().Key(componentUuidIndexPrefix+rq.Uuid).Value(key).Px(rq.Expiration).Build())
+	}
+
+	cmds = append(cmds,
+		b.Hset().Key(key).FieldValue().FieldValue(componentFieldUuid, rq.Uuid).FieldValue(componentFieldData, string(data)).Build(),
+		b.Pexpire().Key(key).Milliseconds(rq.Expiration.Milliseconds()).Build(),
+		b.Sadd().Key(componentTypeIndexPrefix+typeString).Member(key).Build(),
+		b.Sadd().Key(componentIdentifierIndexPrefix+rq.Identifier).Member(key).Build(),
+		b.Zadd().Key(componentDeadlineIndex).ScoreMember().ScoreMember(float64(deadline.UnixMilli()), key).Build(),
+		b.Exec().Build(),
+	)
+
+	for _, resp := range c.s.component.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			return nil, fmt.Errorf("unable to store component: %w", err)
+		}
+	}
+
+	return &componentEntity{Component: rq.Component}, nil
 }
 
 func (c *componentRepository) validateUpdate(ctx context.Context, rq *api.ComponentServiceUpdateRequest, old *componentEntity) error {
-	panic("unimplemented")
+	return errorutil.InvalidArgument("components can not be updated, they are updated by their pings")
 }
 
 func (c *componentRepository) update(ctx context.Context, e *componentEntity, msg *api.ComponentServiceUpdateRequest) (*componentEntity, error) {
-	panic("unimplemented")
+	return e, nil
 }
 
 func (c *componentRepository) validateDelete(ctx context.Context, e *componentEntity) error {
@@ -110,68 +170,202 @@ func (c *componentRepository) validateDelete(ctx context.Context, e *componentEn
 }
 
 func (c *componentRepository) delete(ctx context.Context, e *componentEntity) (*deleteInfo, error) {
-	resp, err := c.get(ctx, e.Uuid)
+	key, err := componentKey(e.Component)
 	if err != nil {
 		return nil, err
 	}
 
-	key, err := c.key(resp.Component)
-	if err != nil {
-		return nil, err
+	b := c.s.component.B()
+	cmds := valkey.Commands{b.Multi().Build(), b.Del().Key(key).Build()}
+	if e.Uuid != "" {
+		cmds = append(cmds, b.Del().Key(componentUuidIndexPrefix+e.Uuid).Build())
 	}
+	cmds = append(cmds, c.removeFromIndexCmds(key)...)
+	cmds = append(cmds, b.Exec().Build())
 
-	return nil, c.s.component.Do(ctx, c.s.component.B().Del().Key(key).Build()).Error()
+	for _, resp := range c.s.component.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			return nil, fmt.Errorf("unable to delete component: %w", err)
+		}
+	}
+
+	return nil, nil
 }
 
 func (c *componentRepository) find(ctx context.Context, query *apiv2.ComponentQuery) (*componentEntity, error) {
-	panic("unimplemented")
+	components, err := c.list(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(components) {
+	case 0:
+		return nil, errorutil.NotFound("no component found")
+	case 1:
+		return components[0], nil
+	default:
+		return nil, fmt.Errorf("more than one component exists")
+	}
 }
 
 func (c *componentRepository) list(ctx context.Context, query *apiv2.ComponentQuery) ([]*componentEntity, error) {
-	// TODO valkey-json would be a perfect fit: https://github.com/valkey-io/valkey-json
-	keys, err := c.s.component.Do(ctx, c.s.component.B().Keys().Pattern(componentPrefix+"*").Build()).AsStrSlice()
+	if query == nil {
+		query = &apiv2.ComponentQuery{}
+	}
+
+	var (
+		b    = c.s.component.B()
+		keys []string
+		err  error
+	)
+
+	switch {
+	case query.Uuid != nil:
+		key, err := c.s.component.Do(ctx, b.Get().Key(componentUuidIndexPrefix+*query.Uuid).Build()).ToString()
+		if valkey.IsValkeyNil(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = []string{key}
+	case query.Type != nil && query.Identifier != nil:
+		key, err := componentKey(&apiv2.Component{Type: *query.Type, Identifier: *query.Identifier})
+		if err != nil {
+			return nil, err
+		}
+		keys = []string{key}
+	case query.Type != nil:
+		typeAsString, err := enum.GetStringValue(*query.Type)
+		if err != nil {
+			return nil, err
+		}
+		keys, err = c.s.component.Do(ctx, b.Smembers().Key(componentTypeIndexPrefix+*typeAsString).Build()).AsStrSlice()
+		if err != nil {
+			return nil, err
+		}
+	case query.Identifier != nil:
+		keys, err = c.s.component.Do(ctx, b.Smembers().Key(componentIdentifierIndexPrefix+*query.Identifier).Build()).AsStrSlice()
+		if err != nil {
+			return nil, err
+		}
+	default:
+		keys, err = c.s.component.Do(ctx, b.Zrange().Key(componentDeadlineIndex).Min("0").Max("-1").Build()).AsStrSlice()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	components, err := c.load(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(components, func(e *componentEntity) bool {
+		if query.Uuid != nil && e.Uuid != *query.Uuid {
+			return true
+		}
+		if query.Identifier != nil && e.Identifier != *query.Identifier {
+			return true
+		}
+		if query.Type != nil && e.Type != *query.Type {
+			return true
+		}
+		return false
+	}), nil
+}
+
+// Missing returns all components which did not report within two of their ping intervals but are not expired yet.
+// Expired components are removed from the indexes on the way.
+func (c *componentRepository) Missing(ctx context.Context) ([]*apiv2.Component, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	keys, err := c.s.component.Do(ctx, c.s.component.B().Zrangebyscore().Key(componentDeadlineIndex).Min("-inf").Max(now).Build()).AsStrSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	components, err := c.load(ctx, keys...)
 	if err != nil {
 		return nil, err
 	}
 
-	var result []*componentEntity
+	var result []*apiv2.Component
+	for _, e := range components {
+		result = append(result, e.Component)
+	}
+
+	return result, nil
+}
+
+// load returns the components of the given keys sorted by key, components which already expired are removed from the indexes.
+func (c *componentRepository) load(ctx context.Context, keys ...string) ([]*componentEntity, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	keys = slices.Sorted(slices.Values(keys))
+
+	var cmds valkey.Commands
 	for _, key := range keys {
-		value, err := c.s.component.Do(ctx, c.s.component.B().Get().Key(key).Build()).AsBytes()
+		cmds = append(cmds, c.s.component.B().Hget().Key(key).Field(componentFieldData).Build())
+	}
+
+	var (
+		result  []*componentEntity
+		expired []string
+	)
+
+	for i, resp := range c.s.component.DoMulti(ctx, cmds...) {
+		data, err := resp.ToString()
+		if valkey.IsValkeyNil(err) {
+			expired = append(expired, keys[i])
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
 
 		var component apiv2.Component
-		err = json.Unmarshal(value, &component)
+		err = protojson.Unmarshal([]byte(data), &component)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("unable to unmarshal component %s: %w", keys[i], err)
 		}
 
-		if query != nil {
-			if query.Uuid != nil && component.Uuid != *query.Uuid {
-				continue
-			}
-			if query.Identifier != nil && component.Identifier != *query.Identifier {
-				continue
-			}
-			if query.Type != nil && component.Type != *query.Type {
-				continue
-			}
+		result = append(result, &componentEntity{Component: &component})
+	}
+
+	if len(expired) > 0 {
+		var cmds valkey.Commands
+		for _, key := range expired {
+			cmds = append(cmds, c.removeFromIndexCmds(key)...)
 		}
 
-		e := &componentEntity{Component: &component}
-		result = append(result, e)
+		for _, resp := range c.s.component.DoMulti(ctx, cmds...) {
+			if err := resp.Error(); err != nil {
+				return nil, fmt.Errorf("unable to remove expired components from index: %w", err)
+			}
+		}
 	}
 
-	sort.SliceStable(result, func(i, j int) bool {
-		return result[i].Uuid > result[j].Uuid
-	})
-
 	return result, nil
 }
 
+func (c *componentRepository) removeFromIndexCmds(key string) valkey.Commands {
+	var (
+		b                      = c.s.component.B()
+		typeString, identifier = componentKeyParts(key)
+	)
+
+	return valkey.Commands{
+		b.Srem().Key(componentTypeIndexPrefix + typeString).Member(key).Build(),
+		b.Srem().Key(componentIdentifierIndexPrefix + identifier).Member(key).Build(),
+		b.Zrem().Key(componentDeadlineIndex).Member(key).Build(),
+	}
+}
+
 func (c *componentRepository) convertToInternal(ctx context.Context, msg *apiv2.Component) (*componentEntity, error) {
-	panic("unimplemented")
+	return &componentEntity{Component: msg}, nil
 }
 
 func (c *componentRepository) convertToProto(ctx context.Context, e *componentEntity) (*apiv2.Component, error) {
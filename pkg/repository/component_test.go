@@ -0,0 +1,98 @@
+package repository_test
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/api/go/errorutil"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func Test_componentRepository_FindAndMissing(t *testing.T) {
+	t.Parallel()
+
+	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := t.Context()
+
+	testStore, repocloser := test.StartRepositoryWithCleanup(t, log, test.WithContainers(false))
+	defer repocloser()
+
+	var (
+		now  = time.Now()
+		core = &apiv2.Component{
+			Uuid:       "00000000-0000-0000-0000-000000000001",
+			Type:       apiv2.ComponentType_COMPONENT_TYPE_METAL_CORE,
+			Identifier: "switch-01",
+			Interval:   durationpb.New(10 * time.Second),
+			ReportedAt: timestamppb.New(now),
+		}
+		console = &apiv2.Component{
+			Uuid:       "00000000-0000-0000-0000-000000000002",
+			Type:       apiv2.ComponentType_COMPONENT_TYPE_METAL_CONSOLE,
+			Identifier: "control-plane",
+			Interval:   durationpb.New(10 * time.Second),
+			// missed more than two pings
+			ReportedAt: timestamppb.New(now.Add(-30 * time.Second)),
+		}
+	)
+
+	for _, c := range []*apiv2.Component{core, console} {
+		_, err := testStore.Component().Create(ctx, &api.ComponentServiceCreateRequest{Component: c, Expiration: time.Hour})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		query   *apiv2.ComponentQuery
+		want    *apiv2.Component
+		wantErr error
+	}{
+		{
+			name:  "find by identifier",
+			query: &apiv2.ComponentQuery{Identifier: new("switch-01")},
+			want:  core,
+		},
+		{
+			name:  "find by type and identifier",
+			query: &apiv2.ComponentQuery{Type: apiv2.ComponentType_COMPONENT_TYPE_METAL_CONSOLE.Enum(), Identifier: new("control-plane")},
+			want:  console,
+		},
+		{
+			name:    "find nothing",
+			query:   &apiv2.ComponentQuery{Identifier: new("switch-02")},
+			wantErr: errorutil.NotFound("no component found"),
+		},
+		{
+			name:    "find more than one",
+			query:   &apiv2.ComponentQuery{},
+			wantErr: errorutil.Internal("more than one component exists"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testStore.Component().Find(ctx, tt.query)
+			if diff := cmp.Diff(err, tt.wantErr, errorutil.ConnectErrorComparer()); diff != "" {
+				t.Errorf("diff = %s", diff)
+				return
+			}
+			if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("diff = %s", diff)
+			}
+		})
+	}
+
+	missing, err := testStore.Component().AdditionalMethods().Missing(ctx)
+	require.NoError(t, err)
+	if diff := cmp.Diff([]*apiv2.Component{console}, missing, protocmp.Transform()); diff != "" {
+		t.Errorf("diff = %s", diff)
+	}
+}
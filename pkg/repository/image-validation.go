@@ -25,10 +25,6 @@ func (r *imageRepository) validateCreate(ctx context.Context, req *adminv2.Image
 		return fmt.Errorf("image url must not be empty")
 	}
 
-	if err := checkIfUrlExists(ctx, "image", image.Id, image.Url); err != nil {
-		return err
-	}
-
 	if len(image.Features) == 0 {
 		return fmt.Errorf("image features must not be empty")
 	}
@@ -61,12 +57,6 @@ func (r *imageRepository) validateUpdate(ctx context.Context, req *adminv2.Image
 		return fmt.Errorf("image id must not be empty")
 	}
 
-	if req.Url != nil {
-		if err := checkIfUrlExists(ctx, "image", req.Id, *req.Url); err != nil {
-			return err
-		}
-	}
-
 	if len(req.Features) > 0 {
 		if _, err := metal.ImageFeaturesFrom(req.Features); err != nil {
 			return err
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/imageverify"
+)
+
+func (r *imageRepository) verify(ctx context.Context, id, url string) (*imageverify.Result, error) {
+	result, err := r.s.imageVerifier.Verify(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("image:%s %w", id, err)
+	}
+
+	return result, nil
+}
+
+// setVerification verifies the url of the image and stores size and checksum on it.
+func (r *imageRepository) setVerification(ctx context.Context, image *metal.Image) error {
+	result, err := r.verify(ctx, image.ID, image.URL)
+	if err != nil {
+		return err
+	}
+
+	image.Size = result.Size
+	image.Checksum = result.Checksum
+	image.LastVerified = result.VerifiedAt
+	image.VerificationError = ""
+
+	return nil
+}
+
+// ImageVerifyHandleFn verifies the urls of all images which are not expired yet.
+// Images which became unreachable are marked with the verification error, they are not removed.
+// Only images whose verification result changed are written.
+func (r *Store) ImageVerifyHandleFn(ctx context.Context, t *asynq.Task) error {
+	_, err := task.DecodePayload[*task.ImageVerifyPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	images, err := r.ds.Image().List(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list images: %w", err)
+	}
+
+	var (
+		repo = &imageRepository{s: r}
+		errs []error
+	)
+
+	for _, image := range images {
+		if !image.ExpirationDate.IsZero() && image.ExpirationDate.Before(time.Now()) {
+			continue
+		}
+
+		var (
+			previous  = *image
+			verifyErr = repo.setVerification(ctx, image)
+		)
+
+		if verifyErr != nil {
+			image.VerificationError = verifyErr.Error()
+			r.log.Error("image is unreachable", "image", image.ID, "url", image.URL, "error", verifyErr)
+		} else if previous.VerificationError != "" {
+			r.log.Info("image is reachable again", "image", image.ID, "url", image.URL)
+		}
+
+		if !verificationChanged(&previous, image) {
+			continue
+		}
+
+		err = r.ds.Image().Update(ctx, image)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to update verification of image %s: %w", image.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func verificationChanged(previous, current *metal.Image) bool {
+	return previous.Size != current.Size ||
+		previous.Checksum != current.Checksum ||
+		previous.VerificationError != current.VerificationError
+}
@@ -0,0 +1,79 @@
+package repository_test
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ImageVerifyHandleFn(t *testing.T) {
+	t.Parallel()
+
+	const checksum = "d41d8cd98f00b204e9800998ecf8427e"
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	var unreachable atomic.Bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unreachable.Load() {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".md5") {
+			_, _ = fmt.Fprintf(w, "%s  img.tar.lz4\n", checksum)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "a image")
+	}))
+	defer ts.Close()
+
+	test.CreateImages(t, testStore, []*adminv2.ImageServiceCreateRequest{
+		{
+			Image: &apiv2.Image{Id: "debian-12.0.20241231", Url: ts.URL + "/img.tar.lz4", Features: []apiv2.ImageFeature{apiv2.ImageFeature_IMAGE_FEATURE_MACHINE}},
+		},
+	})
+
+	image, err := testStore.GetDatastore().Image().Get(t.Context(), "debian-12.0.20241231")
+	require.NoError(t, err)
+	require.Equal(t, checksum, image.Checksum)
+	require.NotZero(t, image.LastVerified)
+	require.Empty(t, image.VerificationError)
+
+	verify := asynq.NewTask(string(task.TypeImageVerify), []byte("{}"))
+
+	require.NoError(t, testStore.ImageVerifyHandleFn(t.Context(), verify))
+
+	unchanged, err := testStore.GetDatastore().Image().Get(t.Context(), "debian-12.0.20241231")
+	require.NoError(t, err)
+	require.Equal(t, image.Generation, unchanged.Generation, "image must not be written if the verification did not change")
+
+	unreachable.Store(true)
+	require.NoError(t, testStore.ImageVerifyHandleFn(t.Context(), verify))
+
+	image, err = testStore.GetDatastore().Image().Get(t.Context(), "debian-12.0.20241231")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("image:debian-12.0.20241231 is not accessible under:%s/img.tar.lz4 statuscode:404", ts.URL), image.VerificationError)
+
+	unreachable.Store(false)
+	require.NoError(t, testStore.ImageVerifyHandleFn(t.Context(), verify))
+
+	image, err = testStore.GetDatastore().Image().Get(t.Context(), "debian-12.0.20241231")
+	require.NoError(t, err)
+	require.Empty(t, image.VerificationError)
+	require.Equal(t, checksum, image.Checksum)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"connectrpc.com/connect"
 	"github.com/Masterminds/semver/v3"
 	metalcommon "github.com/metal-stack/metal-lib/pkg/metal"
 	"github.com/metal-stack/metal-lib/pkg/pointer"
@@ -43,6 +44,11 @@ func (r *imageRepository) create(ctx context.Context, rq *adminv2.ImageServiceCr
 		return nil, err
 	}
 
+	err = r.setVerification(ctx, fsl)
+	if err != nil {
+		return nil, errorutil.WrapConnectErr(connect.CodeInvalidArgument, err)
+	}
+
 	resp, err := r.s.ds.Image().Create(ctx, fsl)
 	if err != nil {
 		return nil, err
@@ -80,6 +86,11 @@ func (r *imageRepository) update(ctx context.Context, e *metal.Image, rq *adminv
 
 	if rq.Url != nil {
 		e.URL = *rq.Url
+
+		err := r.setVerification(ctx, e)
+		if err != nil {
+			return nil, errorutil.WrapConnectErr(connect.CodeInvalidArgument, err)
+		}
 	}
 
 	if rq.Labels != nil {
@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/api/go/enum"
+	"github.com/metal-stack/api/go/errorutil"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultBulkBMCCommandConcurrency = 5
+	// bmcCommandQueue is the queue in which bmc commands are enqueued
+	bmcCommandQueue = "default"
+	// bmcCommandWatchSlack is added to the bmc command timeout when estimating the duration of bmc commands
+	bmcCommandWatchSlack = 10 * time.Second
+)
+
+type (
+	// BulkBMCCommandRequest executes a bmc command against all machines matched by the query.
+	BulkBMCCommandRequest struct {
+		Query   *apiv2.MachineQuery
+		Command apiv2.MachineBMCCommand
+		// Concurrency is the maximum number of commands which are executed at the same time in a partition, defaults to 5
+		Concurrency int
+		// Interval is the minimum duration between two commands which are started in the same partition
+		Interval time.Duration
+	}
+
+	// BulkBMCCommandResult is the outcome of a bulk bmc command for a single machine.
+	BulkBMCCommandResult struct {
+		Machine   string `json:"machine"`
+		Partition string `json:"partition"`
+		// CommandID is the id of the bmc command which was sent to metal-bmc for this machine
+		CommandID string `json:"command_id,omitempty"`
+		// Skipped is the reason why the command was not executed against this machine
+		Skipped string `json:"skipped,omitempty"`
+		// Error is set if the command failed
+		Error string `json:"error,omitempty"`
+	}
+
+	// BulkBMCCommandStatus is the state of a bulk bmc command, the results are available once the task is finished.
+	BulkBMCCommandStatus struct {
+		TaskID  string
+		State   asynq.TaskState
+		Results []*BulkBMCCommandResult
+	}
+)
+
+// BulkBMCCommand enqueues a task which executes the bmc command against all machines matched by the query
+// and returns the id of this parent task. The results are aggregated in the result of the parent task.
+func (r *machineRepository) BulkBMCCommand(ctx context.Context, req *BulkBMCCommandRequest) (string, error) {
+	cmd, err := validateBulkBMCCommandRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	machines, err := r.list(ctx, req.Query)
+	if err != nil {
+		return "", err
+	}
+	if len(machines) == 0 {
+		return "", errorutil.NotFound("no machines found for the given query")
+	}
+
+	concurrency := req.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultBulkBMCCommandConcurrency
+	}
+
+	payload := &task.MachineBulkBMCCommandPayload{
+		Machines:    bulkBMCCommandMachines(machines),
+		Command:     cmd,
+		Concurrency: concurrency,
+		Interval:    req.Interval,
+	}
+
+	info, err := r.s.task.NewTask(payload,
+		asynq.Timeout(bulkBMCCommandTimeout(payload)),
+		asynq.MaxRetry(0),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	r.s.log.Info("machine bulk bmc command scheduled", "task", info.ID, "command", cmd, "machines", len(machines))
+
+	return info.ID, nil
+}
+
+// BulkBMCCommandStatus returns the state and the aggregated results of a bulk bmc command.
+func (r *machineRepository) BulkBMCCommandStatus(taskID string) (*BulkBMCCommandStatus, error) {
+	info, err := r.s.task.GetTaskInfo(bmcCommandQueue, taskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, errorutil.NotFound("bulk bmc command %q not found", taskID)
+		}
+		return nil, err
+	}
+
+	if info.Type != string(task.TypeMachineBulkBMCCommand) {
+		return nil, errorutil.InvalidArgument("task %q is not a bulk bmc command", taskID)
+	}
+
+	status := &BulkBMCCommandStatus{
+		TaskID: info.ID,
+		State:  info.State,
+	}
+
+	if len(info.Result) > 0 {
+		err = json.Unmarshal(info.Result, &status.Results)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode bulk bmc command results: %w", err)
+		}
+	}
+
+	return status, nil
+}
+
+func (r *Store) MachineBulkBMCCommandHandleFn(ctx context.Context, t *asynq.Task) error {
+	payload, err := task.DecodePayload[*task.MachineBulkBMCCommandPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	command, err := enum.GetEnum[apiv2.MachineBMCCommand](payload.Command)
+	if err != nil {
+		return fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+	}
+
+	r.log.Info("machine bulk bmc command handler", "command", payload.Command, "machines", len(payload.Machines))
+
+	var (
+		mu      sync.Mutex
+		results []*BulkBMCCommandResult
+		g       errgroup.Group
+
+		addResult = func(result *BulkBMCCommandResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		}
+	)
+
+	for _, m := range payload.Machines {
+		if m.Skipped != "" {
+			addResult(&BulkBMCCommandResult{Machine: m.UUID, Partition: m.Partition, Skipped: m.Skipped})
+		}
+	}
+
+	for partition, machines := range bulkBMCCommandPartitions(payload.Machines) {
+		g.Go(func() error {
+			// every partition is handled on its own, such that a slow partition does not delay the others
+			var pg errgroup.Group
+			pg.SetLimit(max(payload.Concurrency, 1))
+
+			for i, m := range machines {
+				if i > 0 && payload.Interval > 0 {
+					select {
+					case <-time.After(payload.Interval):
+					case <-ctx.Done():
+						_ = pg.Wait()
+						return fmt.Errorf("bulk bmc command in partition %s cancelled: %w", partition, ctx.Err())
+					}
+				}
+
+				pg.Go(func() error {
+					addResult(r.bulkBMCCommand(ctx, m, command))
+					return nil
+				})
+			}
+
+			return pg.Wait()
+		})
+	}
+
+	err = g.Wait()
+
+	slices.SortFunc(results, func(a, b *BulkBMCCommandResult) int {
+		return cmp.Or(cmp.Compare(a.Partition, b.Partition), cmp.Compare(a.Machine, b.Machine))
+	})
+
+	encoded, encodeErr := json.Marshal(results)
+	if encodeErr != nil {
+		return fmt.Errorf("unable to encode bulk bmc command results: %w", encodeErr)
+	}
+	if _, writeErr := t.ResultWriter().Write(encoded); writeErr != nil {
+		r.log.Warn("machine bulk bmc command handler could not write results to task result", "error", writeErr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("bmc command %s failed on %d of %d machines", payload.Command, failed, len(results))
+	}
+
+	return nil
+}
+
+// bulkBMCCommand executes the bmc command against a single machine of a bulk bmc command. The command is pushed
+// to metal-bmc directly instead of enqueueing a task per machine, which would occupy a worker of the task server
+// for every machine while the bulk bmc command already occupies one waiting for them.
+func (r *Store) bulkBMCCommand(ctx context.Context, m task.BulkBMCCommandMachine, command apiv2.MachineBMCCommand) *BulkBMCCommandResult {
+	result := &BulkBMCCommandResult{
+		Machine:   m.UUID,
+		Partition: m.Partition,
+	}
+
+	cmd, err := enum.GetStringValue(command)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	payload := &task.MachineBMCCommandPayload{
+		UUID:      m.UUID,
+		Partition: m.Partition,
+		Command:   *cmd,
+		CommandID: machineBMCCommandID(m.UUID, *cmd),
+	}
+	result.CommandID = payload.CommandID
+
+	err = r.executeMachineBMCCommand(ctx, payload)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// validateBulkBMCCommandRequest validates the request and returns the string value of the command.
+func validateBulkBMCCommandRequest(req *BulkBMCCommandRequest) (string, error) {
+	if req.Query == nil || proto.Equal(req.Query, &apiv2.MachineQuery{}) {
+		return "", errorutil.InvalidArgument("a machine query must be given, executing a bmc command against all machines is not allowed")
+	}
+	if req.Concurrency < 0 {
+		return "", errorutil.InvalidArgument("concurrency must not be negative")
+	}
+	if req.Interval < 0 {
+		return "", errorutil.InvalidArgument("interval must not be negative")
+	}
+
+	cmd, err := enum.GetStringValue(req.Command)
+	if err != nil {
+		return "", errorutil.NewInvalidArgument(err)
+	}
+
+	return *cmd, nil
+}
+
+// bulkBMCCommandMachines returns the machines of a bulk bmc command sorted by partition,
+// locked machines and machines without bmc connection details are skipped.
+func bulkBMCCommandMachines(machines []*metal.Machine) []task.BulkBMCCommandMachine {
+	var result []task.BulkBMCCommandMachine
+
+	for _, m := range machines {
+		bm := task.BulkBMCCommandMachine{
+			UUID:      m.ID,
+			Partition: m.PartitionID,
+		}
+
+		switch {
+		case m.State.Value == metal.LockedState:
+			bm.Skipped = "machine is locked"
+		case m.IPMI.Address == "" || m.IPMI.User == "" || m.IPMI.Password == "":
+			bm.Skipped = "machine does not have bmc connection details yet"
+		}
+
+		result = append(result, bm)
+	}
+
+	slices.SortFunc(result, func(a, b task.BulkBMCCommandMachine) int {
+		return cmp.Or(cmp.Compare(a.Partition, b.Partition), cmp.Compare(a.UUID, b.UUID))
+	})
+
+	return result
+}
+
+// bulkBMCCommandPartitions groups the machines which are not skipped by partition.
+func bulkBMCCommandPartitions(machines []task.BulkBMCCommandMachine) map[string][]task.BulkBMCCommandMachine {
+	result := map[string][]task.BulkBMCCommandMachine{}
+
+	for _, m := range machines {
+		if m.Skipped != "" {
+			continue
+		}
+		result[m.Partition] = append(result[m.Partition], m)
+	}
+
+	return result
+}
+
+// bulkBMCCommandTimeout returns an upper bound of the duration a bulk bmc command takes,
+// which is defined by the partition with the most machines.
+func bulkBMCCommandTimeout(payload *task.MachineBulkBMCCommandPayload) time.Duration {
+	var (
+		concurrency = max(payload.Concurrency, 1)
+		longest     time.Duration
+	)
+
+	for _, machines := range bulkBMCCommandPartitions(payload.Machines) {
+		batches := (len(machines) + concurrency - 1) / concurrency
+
+		d := time.Duration(len(machines)-1)*payload.Interval + time.Duration(batches)*(machineBMCCommandTimeout+bmcCommandWatchSlack)
+		longest = max(longest, d)
+	}
+
+	return longest + time.Minute
+}
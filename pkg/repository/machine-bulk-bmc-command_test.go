@@ -0,0 +1,126 @@
+package repository_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/queue"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/repository"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MachineBulkBMCCommandHandleFn(t *testing.T) {
+	t.Parallel()
+
+	const (
+		partition = "partition-1"
+		m1        = "00000000-0000-0000-0000-000000000001"
+		m2        = "00000000-0000-0000-0000-000000000002"
+		m3        = "00000000-0000-0000-0000-000000000003"
+		m4        = "00000000-0000-0000-0000-000000000004"
+	)
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	ipmi := metal.IPMI{Address: "192.168.0.1:623", User: "metal", Password: "secret"}
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{Base: metal.Base{ID: m4}, PartitionID: partition, IPMI: ipmi, State: metal.MachineState{Value: metal.LockedState}},
+		{Base: metal.Base{ID: m1}, PartitionID: partition, IPMI: ipmi},
+		{Base: metal.Base{ID: m3}, PartitionID: partition},
+		{Base: metal.Base{ID: m2}, PartitionID: partition, IPMI: ipmi},
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	// metal-bmc is simulated directly on the queue, the command of the second machine fails
+	q := queue.New(log, testStore.GetValkeyClient())
+	go func() {
+		for cmd := range q.WaitMachineCommand(ctx, partition) {
+			done := task.BMCCommandDonePayload{}
+			if cmd.UUID == m2 {
+				done.Error = new("bmc not reachable")
+			}
+			_ = q.PushMachineCommandDone(ctx, cmd.CommandID, done)
+		}
+	}()
+
+	taskID, err := testStore.UnscopedMachine().AdditionalMethods().BulkBMCCommand(ctx, &repository.BulkBMCCommandRequest{
+		Query:   &apiv2.MachineQuery{Partition: new(partition)},
+		Command: apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE,
+	})
+	require.NoError(t, err)
+
+	var status *repository.BulkBMCCommandStatus
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		status, err = testStore.UnscopedMachine().AdditionalMethods().BulkBMCCommandStatus(taskID)
+		require.NoError(c, err)
+		require.Equal(c, asynq.TaskStateArchived, status.State)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// only the bulk bmc command itself is a task, the commands of the machines are pushed to metal-bmc directly
+	tasks, err := testStore.Task().List(nil)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	require.Len(t, status.Results, 4)
+	require.Equal(t, m1, status.Results[0].Machine)
+	require.Empty(t, status.Results[0].Error)
+	require.NotEmpty(t, status.Results[0].CommandID)
+	require.Equal(t, m2, status.Results[1].Machine)
+	require.Equal(t, "bmc not reachable", status.Results[1].Error)
+	require.Equal(t, m3, status.Results[2].Machine)
+	require.Equal(t, "machine does not have bmc connection details yet", status.Results[2].Skipped)
+	require.Equal(t, m4, status.Results[3].Machine)
+	require.Equal(t, "machine is locked", status.Results[3].Skipped)
+	require.NotEqual(t, status.Results[0].CommandID, status.Results[1].CommandID)
+}
+
+func Test_BulkBMCCommand(t *testing.T) {
+	t.Parallel()
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	tests := []struct {
+		name    string
+		req     *repository.BulkBMCCommandRequest
+		wantErr string
+	}{
+		{
+			name:    "all machines",
+			req:     &repository.BulkBMCCommandRequest{Query: &apiv2.MachineQuery{}, Command: apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE},
+			wantErr: "a machine query must be given, executing a bmc command against all machines is not allowed",
+		},
+		{
+			name:    "negative concurrency",
+			req:     &repository.BulkBMCCommandRequest{Query: &apiv2.MachineQuery{Partition: new("partition-1")}, Command: apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE, Concurrency: -1},
+			wantErr: "concurrency must not be negative",
+		},
+		{
+			name:    "no machines",
+			req:     &repository.BulkBMCCommandRequest{Query: &apiv2.MachineQuery{Partition: new("partition-1")}, Command: apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE},
+			wantErr: "no machines found for the given query",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testStore.UnscopedMachine().AdditionalMethods().BulkBMCCommand(t.Context(), tt.req)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/metal-stack/api/go/errorutil"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/console"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/token"
+	"github.com/metal-stack/metal-lib/auditing"
+)
+
+const (
+	defaultConsoleTicketTTL = 5 * time.Minute
+	maxConsoleTicketTTL     = time.Hour
+
+	consoleTicketRedeemAuditPath = "console:redeem-ticket"
+)
+
+// IssueConsoleTicket mints a one-time console ticket for the calling user to a machine of the project.
+// Only owners and editors of the project can get console access, the ticket is delivered to the serial
+// console gateway of the partition of the machine and expires after the given ttl, five minutes if zero.
+func (r *machineRepository) IssueConsoleTicket(ctx context.Context, machineID string, ttl time.Duration) (*console.Ticket, error) {
+	if r.scope == nil {
+		return nil, errorutil.FailedPrecondition("console tickets can only be issued for machines of a project")
+	}
+	if r.s.consoleTickets == nil {
+		return nil, errorutil.FailedPrecondition("console tickets are not configured")
+	}
+
+	if ttl == 0 {
+		ttl = defaultConsoleTicketTTL
+	}
+	if ttl < 0 || ttl > maxConsoleTicketTTL {
+		return nil, errorutil.InvalidArgument("ttl must be between 0 and %s", maxConsoleTicketTTL)
+	}
+
+	tok, ok := token.TokenFromContext(ctx)
+	if !ok || tok == nil {
+		return nil, errorutil.Unauthenticated("no token found in request")
+	}
+
+	projectsAndTenants, err := r.s.UnscopedProject().AdditionalMethods().GetProjectsAndTenants(ctx, tok.User)
+	if err != nil {
+		return nil, err
+	}
+	if !consoleAccessAllowed(projectsAndTenants.ProjectRoles[r.scope.projectID]) {
+		return nil, errorutil.PermissionDenied("user %s is not allowed to access the console of machines of project %s", tok.User, r.scope.projectID)
+	}
+
+	m, err := r.get(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+	if !r.matchScope(m) {
+		return nil, errorutil.NotFound("no machine with id %q found", machineID)
+	}
+
+	secret, err := console.GenerateTicketSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	ticket := &console.Ticket{
+		Secret:    secret,
+		Machine:   m.ID,
+		Partition: m.PartitionID,
+		Project:   r.scope.projectID,
+		User:      tok.User,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	err = r.s.consoleTickets.SetTicket(ctx, ticket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to store console ticket: %w", err)
+	}
+
+	if r.s.consoleBackend != nil {
+		err = r.s.consoleBackend.Deliver(ctx, ticket)
+		if err != nil {
+			// the ticket is useless if the gateway does not know about it
+			if _, redeemErr := r.s.consoleTickets.RedeemTicket(ctx, ticket.Secret, ticket.Machine, ticket.User); redeemErr != nil {
+				r.s.log.Warn("unable to invalidate undelivered console ticket", "machine", m.ID, "error", redeemErr)
+			}
+			return nil, errorutil.FailedPrecondition("unable to deliver console ticket to the serial console gateway: %w", err)
+		}
+	}
+
+	r.s.log.Info("console ticket issued", "machine", m.ID, "project", ticket.Project, "user", ticket.User, "expires", ticket.ExpiresAt)
+
+	r.archiveTimeline(ctx, newMachineTimelineEntry(m.ID, metal.MachineTimelineConsoleAccess, ticket.Project, ticket.User, fmt.Sprintf("console ticket issued, expires at %s", ticket.ExpiresAt.Format(time.RFC3339))))
+
+	return ticket, nil
+}
+
+// RedeemConsoleTicket is called by the serial console gateway when a user connects to the console of a machine,
+// the user is the one the gateway authenticated. It returns the ticket and invalidates it, such that it cannot be used
+// again. The ticket is rejected if it was issued for another user or machine, or if the machine is not allocated to the
+// project the ticket was issued for anymore. Every attempt is audited.
+func (r *machineRepository) RedeemConsoleTicket(ctx context.Context, machineID, user, secret string) (*console.Ticket, error) {
+	ticket, err := r.redeemConsoleTicket(ctx, machineID, user, secret)
+
+	var project string
+	if ticket != nil {
+		project = ticket.Project
+	}
+
+	r.s.auditEvent(auditing.Entry{
+		User:    user,
+		Project: project,
+		Path:    consoleTicketRedeemAuditPath,
+		Body: map[string]any{
+			"machine": machineID,
+		},
+		Error: err,
+	})
+
+	return ticket, err
+}
+
+func (r *machineRepository) redeemConsoleTicket(ctx context.Context, machineID, user, secret string) (*console.Ticket, error) {
+	if r.s.consoleTickets == nil {
+		return nil, errorutil.FailedPrecondition("console tickets are not configured")
+	}
+
+	ticket, err := r.s.consoleTickets.RedeemTicket(ctx, secret, machineID, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, console.ErrTicketNotFound):
+			return nil, errorutil.NotFound("console ticket not found, it was already used or expired")
+		case errors.Is(err, console.ErrTicketNotIssued):
+			return nil, errorutil.NewPermissionDenied(err)
+		case errors.Is(err, console.ErrInvalidTicketSecret):
+			return nil, errorutil.NewInvalidArgument(err)
+		default:
+			return nil, errorutil.NewInternal(err)
+		}
+	}
+
+	m, err := r.get(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Allocation == nil || m.Allocation.Project != ticket.Project {
+		return nil, errorutil.FailedPrecondition("machine %s is not allocated to project %s anymore", machineID, ticket.Project)
+	}
+
+	r.s.log.Info("console ticket redeemed", "machine", m.ID, "project", ticket.Project, "user", ticket.User)
+
+	r.archiveTimeline(ctx, newMachineTimelineEntry(m.ID, metal.MachineTimelineConsoleAccess, ticket.Project, ticket.User, "console ticket redeemed"))
+
+	return ticket, nil
+}
+
+func consoleAccessAllowed(role apiv2.ProjectRole) bool {
+	switch role {
+	case apiv2.ProjectRole_PROJECT_ROLE_OWNER, apiv2.ProjectRole_PROJECT_ROLE_EDITOR:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,103 @@
+package repository_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/metal-stack/metal-apiserver/pkg/token"
+	"github.com/metal-stack/metal-lib/auditing"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_machineRepository_ConsoleTicket(t *testing.T) {
+	t.Parallel()
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log, test.WithPostgres(true))
+	defer closer()
+
+	test.CreateTenants(t, testStore, []*apiv2.TenantServiceCreateRequest{
+		{Name: "john.doe@github.com"},
+		{Name: "viewer@github.com"},
+	})
+	test.CreateTenantMemberships(t, testStore, "john.doe@github.com", []*api.TenantMemberCreateRequest{
+		{MemberID: "john.doe@github.com", Role: apiv2.TenantRole_TENANT_ROLE_OWNER},
+	})
+	test.CreateTenantMemberships(t, testStore, "viewer@github.com", []*api.TenantMemberCreateRequest{
+		{MemberID: "viewer@github.com", Role: apiv2.TenantRole_TENANT_ROLE_OWNER},
+	})
+	projectMap := test.CreateProjects(t, testStore, []*apiv2.ProjectServiceCreateRequest{
+		{Login: "john.doe@github.com"},
+	})
+	project := projectMap["john.doe@github.com"]
+	test.CreateProjectMemberships(t, testStore, project, []*api.ProjectMemberCreateRequest{
+		{TenantId: "viewer@github.com", Role: apiv2.ProjectRole_PROJECT_ROLE_VIEWER},
+	})
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{Base: metal.Base{ID: "m1"}, PartitionID: "partition-one", Allocation: &metal.MachineAllocation{Project: project}},
+		{Base: metal.Base{ID: "m2"}, PartitionID: "partition-one", Allocation: &metal.MachineAllocation{Project: "other-project"}},
+	})
+
+	var (
+		ctx       = token.ContextWithToken(t.Context(), &apiv2.Token{User: "john.doe@github.com"})
+		viewerCtx = token.ContextWithToken(t.Context(), &apiv2.Token{User: "viewer@github.com"})
+		machines  = testStore.Machine(project).AdditionalMethods()
+	)
+
+	_, err := machines.IssueConsoleTicket(viewerCtx, "m1", 0)
+	require.EqualError(t, err, "permission_denied: user viewer@github.com is not allowed to access the console of machines of project "+project)
+
+	_, err = machines.IssueConsoleTicket(ctx, "m2", 0)
+	require.EqualError(t, err, `not_found: no machine with id "m2" found`)
+
+	_, err = machines.IssueConsoleTicket(ctx, "m1", 2*time.Hour)
+	require.EqualError(t, err, "invalid_argument: ttl must be between 0 and 1h0m0s")
+
+	ticket, err := machines.IssueConsoleTicket(ctx, "m1", 0)
+	require.NoError(t, err)
+	require.Equal(t, "m1", ticket.Machine)
+	require.Equal(t, "partition-one", ticket.Partition)
+	require.Equal(t, project, ticket.Project)
+	require.Equal(t, "john.doe@github.com", ticket.User)
+	require.WithinDuration(t, time.Now().Add(5*time.Minute), ticket.ExpiresAt, time.Minute)
+
+	_, err = testStore.UnscopedMachine().AdditionalMethods().RedeemConsoleTicket(t.Context(), "m1", "john.doe@github.com", "foo")
+	require.EqualError(t, err, "invalid_argument: invalid console ticket secret: unexpected length")
+
+	_, err = testStore.UnscopedMachine().AdditionalMethods().RedeemConsoleTicket(t.Context(), "m2", "john.doe@github.com", ticket.Secret)
+	require.EqualError(t, err, "permission_denied: console ticket was not issued for machine m2")
+
+	_, err = testStore.UnscopedMachine().AdditionalMethods().RedeemConsoleTicket(t.Context(), "m1", "viewer@github.com", ticket.Secret)
+	require.EqualError(t, err, "permission_denied: console ticket was not issued for user viewer@github.com")
+
+	// failed attempts must not invalidate the ticket
+	redeemed, err := testStore.UnscopedMachine().AdditionalMethods().RedeemConsoleTicket(t.Context(), "m1", "john.doe@github.com", ticket.Secret)
+	require.NoError(t, err)
+	require.Equal(t, "john.doe@github.com", redeemed.User)
+
+	_, err = testStore.UnscopedMachine().AdditionalMethods().RedeemConsoleTicket(t.Context(), "m1", "john.doe@github.com", ticket.Secret)
+	require.EqualError(t, err, "not_found: console ticket not found, it was already used or expired")
+
+	entries, err := testStore.GetAuditBackend().Search(t.Context(), auditing.EntryFilter{
+		From: time.Now().Add(-time.Hour),
+		To:   time.Now().Add(time.Hour),
+		Path: "console:redeem-ticket",
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	var redemptions []string
+	for _, e := range entries {
+		if e.Error == nil {
+			redemptions = append(redemptions, e.User+":"+e.Project)
+		}
+	}
+	require.Equal(t, []string{"john.doe@github.com:" + project}, redemptions)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/metal-stack/api/go/errorutil"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+)
+
+// ListFirmwareCatalogs returns all firmware catalogs.
+func (r *machineRepository) ListFirmwareCatalogs(ctx context.Context) ([]*metal.FirmwareCatalog, error) {
+	catalogs, err := r.s.ds.FirmwareCatalog().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(catalogs, func(a, b *metal.FirmwareCatalog) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return catalogs, nil
+}
+
+// SetFirmwareCatalog creates or replaces the firmware catalog of a kind, vendor and board.
+func (r *machineRepository) SetFirmwareCatalog(ctx context.Context, catalog *metal.FirmwareCatalog) (*metal.FirmwareCatalog, error) {
+	if catalog == nil {
+		return nil, errorutil.InvalidArgument("firmware catalog must not be empty")
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, errorutil.NewInvalidArgument(err)
+	}
+
+	catalog.ID = metal.FirmwareCatalogID(catalog.Kind, catalog.Vendor, catalog.Board)
+
+	existing, err := r.s.ds.FirmwareCatalog().Get(ctx, catalog.ID)
+	if err != nil && !errorutil.IsNotFound(err) {
+		return nil, err
+	}
+	if existing != nil {
+		catalog.Created = existing.Created
+		catalog.Generation = existing.Generation
+	}
+
+	err = r.s.ds.FirmwareCatalog().Upsert(ctx, catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+// DeleteFirmwareCatalog removes a firmware catalog.
+func (r *machineRepository) DeleteFirmwareCatalog(ctx context.Context, id string) (*metal.FirmwareCatalog, error) {
+	catalog, err := r.s.ds.FirmwareCatalog().Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.s.ds.FirmwareCatalog().Delete(ctx, catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+// FirmwareCompliance compares the firmware of all machines with the target revision of the catalog of their board.
+// Machines whose board is not contained in any catalog are not returned.
+func (r *machineRepository) FirmwareCompliance(ctx context.Context, kind metal.FirmwareKind, onlyOutdated bool) ([]*metal.FirmwareCompliance, error) {
+	catalogs, err := r.ListFirmwareCatalogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	machines, err := r.list(ctx, &apiv2.MachineQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*metal.FirmwareCompliance
+	for _, catalog := range catalogs {
+		if kind != "" && catalog.Kind != kind {
+			continue
+		}
+
+		for _, m := range machines {
+			if !catalog.Matches(m) {
+				continue
+			}
+
+			compliance := catalog.Compliance(m)
+			if onlyOutdated && !compliance.Outdated {
+				continue
+			}
+
+			result = append(result, compliance)
+		}
+	}
+
+	return result, nil
+}
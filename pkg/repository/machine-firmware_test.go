@@ -0,0 +1,153 @@
+package repository_test
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/metal-stack/api/go/errorutil"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_machineRepository_FirmwareCatalog(t *testing.T) {
+	t.Parallel()
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	machines := testStore.UnscopedMachine().AdditionalMethods()
+
+	_, err := machines.SetFirmwareCatalog(t.Context(), &metal.FirmwareCatalog{Kind: "nic", Vendor: "Supermicro", Board: "X11DPT-B"})
+	require.EqualError(t, err, `invalid_argument: invalid kind:"nic", must be one of [bios bmc]`)
+
+	created, err := machines.SetFirmwareCatalog(t.Context(), &metal.FirmwareCatalog{
+		Kind:      metal.FirmwareKindBIOS,
+		Vendor:    "Supermicro",
+		Board:     "X11DPT-B",
+		Target:    "3.2",
+		Revisions: []metal.FirmwareRevision{{Revision: "3.2", URL: "http://firmware/x11dpt-b-3.2.zip"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "bios:Supermicro:X11DPT-B", created.ID)
+
+	updated, err := machines.SetFirmwareCatalog(t.Context(), &metal.FirmwareCatalog{
+		Kind:   metal.FirmwareKindBIOS,
+		Vendor: "Supermicro",
+		Board:  "X11DPT-B",
+		Target: "3.3",
+		Revisions: []metal.FirmwareRevision{
+			{Revision: "3.2", URL: "http://firmware/x11dpt-b-3.2.zip"},
+			{Revision: "3.3", URL: "http://firmware/x11dpt-b-3.3.zip"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, created.ID, updated.ID)
+	require.Equal(t, created.Generation+1, updated.Generation)
+
+	_, err = machines.SetFirmwareCatalog(t.Context(), &metal.FirmwareCatalog{
+		Kind:      metal.FirmwareKindBMC,
+		Vendor:    "Supermicro",
+		Board:     "X11DPT-B",
+		Target:    "1.73.07",
+		Revisions: []metal.FirmwareRevision{{Revision: "1.73.07", URL: "http://firmware/x11dpt-b-bmc-1.73.07.bin"}},
+	})
+	require.NoError(t, err)
+
+	catalogs, err := machines.ListFirmwareCatalogs(t.Context())
+	require.NoError(t, err)
+	require.Len(t, catalogs, 2)
+	require.Equal(t, "bios:Supermicro:X11DPT-B", catalogs[0].ID)
+	require.Equal(t, "3.3", catalogs[0].Target)
+	require.Equal(t, "bmc:Supermicro:X11DPT-B", catalogs[1].ID)
+
+	deleted, err := machines.DeleteFirmwareCatalog(t.Context(), "bmc:Supermicro:X11DPT-B")
+	require.NoError(t, err)
+	require.Equal(t, "bmc:Supermicro:X11DPT-B", deleted.ID)
+
+	_, err = machines.DeleteFirmwareCatalog(t.Context(), "bmc:Supermicro:X11DPT-B")
+	require.True(t, errorutil.IsNotFound(err))
+
+	catalogs, err = machines.ListFirmwareCatalogs(t.Context())
+	require.NoError(t, err)
+	require.Len(t, catalogs, 1)
+}
+
+func Test_machineRepository_FirmwareCompliance(t *testing.T) {
+	t.Parallel()
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	var (
+		x11 = metal.IPMI{Fru: metal.Fru{BoardMfg: "Supermicro", BoardPartNumber: "X11DPT-B"}, BMCVersion: "1.73.07"}
+		x12 = metal.IPMI{Fru: metal.Fru{BoardMfg: "Supermicro", BoardPartNumber: "X12"}}
+	)
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{Base: metal.Base{ID: "m1"}, PartitionID: "partition-a", IPMI: x11, BIOS: metal.BIOS{Version: "3.2"}},
+		{Base: metal.Base{ID: "m2"}, PartitionID: "partition-a", IPMI: x11, BIOS: metal.BIOS{Version: "3.3"}},
+		{Base: metal.Base{ID: "m3"}, PartitionID: "partition-b", IPMI: x12, BIOS: metal.BIOS{Version: "1.0"}},
+	})
+
+	machines := testStore.UnscopedMachine().AdditionalMethods()
+
+	for _, catalog := range []*metal.FirmwareCatalog{
+		{
+			Kind:      metal.FirmwareKindBIOS,
+			Vendor:    "Supermicro",
+			Board:     "X11DPT-B",
+			Target:    "3.3",
+			Revisions: []metal.FirmwareRevision{{Revision: "3.3", URL: "http://firmware/x11dpt-b-3.3.zip"}},
+		},
+		{
+			Kind:      metal.FirmwareKindBMC,
+			Vendor:    "Supermicro",
+			Board:     "X11DPT-B",
+			Target:    "1.73.07",
+			Revisions: []metal.FirmwareRevision{{Revision: "1.73.07", URL: "http://firmware/x11dpt-b-bmc-1.73.07.bin"}},
+		},
+	} {
+		_, err := machines.SetFirmwareCatalog(t.Context(), catalog)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name         string
+		kind         metal.FirmwareKind
+		onlyOutdated bool
+		want         []string
+	}{
+		{
+			name: "all kinds",
+			want: []string{"bios:m1:true", "bios:m2:false", "bmc:m1:false", "bmc:m2:false"},
+		},
+		{
+			name: "only bios",
+			kind: metal.FirmwareKindBIOS,
+			want: []string{"bios:m1:true", "bios:m2:false"},
+		},
+		{
+			name:         "only outdated",
+			onlyOutdated: true,
+			want:         []string{"bios:m1:true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compliances, err := machines.FirmwareCompliance(t.Context(), tt.kind, tt.onlyOutdated)
+			require.NoError(t, err)
+
+			var got []string
+			for _, c := range compliances {
+				got = append(got, fmt.Sprintf("%s:%s:%t", c.Kind, c.Machine, c.Outdated))
+			}
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
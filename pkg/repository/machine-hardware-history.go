@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/db/queries"
+)
+
+// HardwareHistory returns all revisions of the hardware a machine reported on registration starting with the latest.
+func (r *machineRepository) HardwareHistory(ctx context.Context, machineID string) ([]*metal.MachineHardwareSnapshot, error) {
+	_, err := r.s.ds.Machine().Get(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.s.ds.MachineHardwareSnapshot().List(ctx, queries.MachineHardwareSnapshotFilter(machineID, 0))
+}
+
+// AcknowledgeHardwareChanges clears the hardware changes of a machine which were detected on registration,
+// the machine does not have a hardware-changed issue anymore afterwards.
+func (r *machineRepository) AcknowledgeHardwareChanges(ctx context.Context, machineID string) (*metal.Machine, error) {
+	m, err := r.s.ds.Machine().Get(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.HardwareChanges) == 0 {
+		return m, nil
+	}
+
+	r.s.log.Info("hardware changes acknowledged", "machine", m.ID, "changes", m.HardwareChanges)
+
+	m.HardwareChanges = nil
+
+	err = r.s.ds.Machine().Update(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// recordHardwareSnapshot stores a new revision of the hardware of a machine if it is the first registration of the machine
+// or the hardware changed since the previous registration.
+func (r *machineRepository) recordHardwareSnapshot(ctx context.Context, machineID string, hardware metal.MachineHardware, bios metal.BIOS, changes []metal.HardwareChange) error {
+	latest, err := r.s.ds.MachineHardwareSnapshot().List(ctx, queries.MachineHardwareSnapshotFilter(machineID, 1))
+	if err != nil {
+		return err
+	}
+
+	revision := 1
+	if len(latest) > 0 {
+		// compared to the latest snapshot instead of the changes, a retried registration must not store the same revision twice
+		if len(metal.DiffHardware(latest[0].Hardware, latest[0].BIOS, hardware, bios)) == 0 {
+			return nil
+		}
+		revision = latest[0].Revision + 1
+	}
+
+	if len(changes) > 0 {
+		r.s.log.Warn("hardware of machine changed since previous registration", "machine", machineID, "revision", revision, "changes", changes)
+	}
+
+	return r.s.ds.MachineHardwareSnapshot().Upsert(ctx, &metal.MachineHardwareSnapshot{
+		Base: metal.Base{
+			ID: metal.MachineHardwareSnapshotID(machineID, revision),
+		},
+		Machine:  machineID,
+		Revision: revision,
+		Taken:    time.Now(),
+		Hardware: hardware,
+		BIOS:     bios,
+		Changes:  changes,
+	})
+}
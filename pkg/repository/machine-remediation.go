@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/api/go/enum"
+	"github.com/metal-stack/api/go/errorutil"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-lib/auditing"
+)
+
+const (
+	machineRemediationIssuer = "machine remediation"
+	// machineRemediationTimeout covers the two bmc commands which are issued to retry the pxe boot
+	machineRemediationTimeout = 2 * (machineBMCCommandTimeout + bmcCommandWatchSlack)
+)
+
+// ListRemediationPolicies returns the remediation policies of all partitions.
+func (r *machineRepository) ListRemediationPolicies(ctx context.Context) ([]*metal.MachineRemediationPolicy, error) {
+	policies, err := r.s.ds.MachineRemediationPolicy().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(policies, func(a, b *metal.MachineRemediationPolicy) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return policies, nil
+}
+
+// SetRemediationPolicy creates or replaces the remediation policy of a partition.
+func (r *machineRepository) SetRemediationPolicy(ctx context.Context, policy *metal.MachineRemediationPolicy) (*metal.MachineRemediationPolicy, error) {
+	if policy == nil {
+		return nil, errorutil.InvalidArgument("remediation policy must not be empty")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, errorutil.NewInvalidArgument(err)
+	}
+
+	_, err := r.s.ds.Partition().Get(ctx, policy.ID)
+	if err != nil {
+		return nil, errorutil.FailedPrecondition("partition must exist before creating a remediation policy: %w", err)
+	}
+
+	existing, err := r.s.ds.MachineRemediationPolicy().Get(ctx, policy.ID)
+	if err != nil && !errorutil.IsNotFound(err) {
+		return nil, err
+	}
+	if existing != nil {
+		policy.Created = existing.Created
+		policy.Generation = existing.Generation
+	}
+
+	err = r.s.ds.MachineRemediationPolicy().Upsert(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// DeleteRemediationPolicy removes the remediation policy of a partition, its machines are not remediated anymore.
+func (r *machineRepository) DeleteRemediationPolicy(ctx context.Context, partition string) (*metal.MachineRemediationPolicy, error) {
+	policy, err := r.s.ds.MachineRemediationPolicy().Get(ctx, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.s.ds.MachineRemediationPolicy().Delete(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// remediate enqueues the remediation of a machine on which a trigger occurred, if the remediation policy of its partition
+// defines an action for it. Enqueueing is best effort, the provisioning event which caused the trigger must be stored anyway.
+// The remediation is skipped while a previous remediation of the machine is still pending, otherwise a machine could be
+// power cycled while it is power cycled.
+func (r *machineRepository) remediate(ctx context.Context, m *metal.Machine, trigger metal.RemediationTrigger, count int) {
+	if m.PartitionID == "" {
+		return
+	}
+
+	policy, err := r.s.ds.MachineRemediationPolicy().Get(ctx, m.PartitionID)
+	if err != nil {
+		if !errorutil.IsNotFound(err) {
+			r.s.log.Warn("unable to get machine remediation policy", "machine", m.ID, "partition", m.PartitionID, "error", err)
+		}
+		return
+	}
+	if policy.Action(trigger, count) == metal.RemediationActionNone {
+		return
+	}
+
+	taskID := machineRemediationTaskID(m.ID)
+
+	previous, err := r.s.task.GetTaskInfo(bmcCommandQueue, taskID)
+	switch {
+	case err == nil && previous.State != asynq.TaskStateCompleted && previous.State != asynq.TaskStateArchived:
+		r.s.log.Info("machine remediation skipped, previous remediation is still pending", "machine", m.ID, "trigger", trigger, "count", count, "task", taskID, "state", previous.State)
+		return
+	case err == nil:
+		// the task id is only released once the previous remediation is deleted
+		err = r.s.task.DeleteTask(bmcCommandQueue, taskID)
+		if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+			r.s.log.Warn("unable to delete previous machine remediation", "machine", m.ID, "task", taskID, "error", err)
+			return
+		}
+	case !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound):
+		r.s.log.Warn("unable to get previous machine remediation", "machine", m.ID, "task", taskID, "error", err)
+		return
+	}
+
+	info, err := r.s.task.NewTask(&task.MachineRemediationPayload{
+		UUID:    m.ID,
+		Trigger: string(trigger),
+		Count:   count,
+	},
+		asynq.TaskID(taskID),
+		asynq.Timeout(machineRemediationTimeout),
+		// a retry could power cycle a machine twice
+		asynq.MaxRetry(0),
+	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		r.s.log.Info("machine remediation skipped, remediation was enqueued concurrently", "machine", m.ID, "trigger", trigger, "count", count, "task", taskID)
+		return
+	}
+	if err != nil {
+		r.s.log.Warn("unable to enqueue machine remediation", "machine", m.ID, "trigger", trigger, "count", count, "error", err)
+		return
+	}
+
+	r.s.log.Info("machine remediation enqueued", "machine", m.ID, "trigger", trigger, "count", count, "task", info.ID)
+}
+
+// machineRemediationTaskID returns the id of the remediation task of a machine, there is at most one per machine.
+func machineRemediationTaskID(machineID string) string {
+	return "machine-remediation:" + machineID
+}
+
+// MachineRemediationHandleFn takes the action the remediation policy of the partition of the machine defines for the trigger.
+// Every action is audited and recorded in the timeline of the machine, together with the reason why it was skipped or failed.
+func (r *Store) MachineRemediationHandleFn(ctx context.Context, t *asynq.Task) error {
+	payload, err := task.DecodePayload[*task.MachineRemediationPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	m, err := r.ds.Machine().Get(ctx, payload.UUID)
+	if err != nil {
+		return err
+	}
+	if m.PartitionID == "" {
+		r.log.Debug("machine remediation skipped, machine is not registered yet", "machine", m.ID)
+		return nil
+	}
+
+	policy, err := r.ds.MachineRemediationPolicy().Get(ctx, m.PartitionID)
+	if err != nil {
+		if errorutil.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	var (
+		trigger = metal.RemediationTrigger(payload.Trigger)
+		action  = policy.Action(trigger, payload.Count)
+		reason  = fmt.Sprintf("%s after %d %s in a row", action, payload.Count, trigger)
+		result  string
+	)
+
+	if action == metal.RemediationActionNone {
+		return nil
+	}
+
+	if skip := remediationSkipReason(m, action); skip != "" {
+		result = reason + " skipped: " + skip
+	} else {
+		err = r.remediateMachine(ctx, m, action, reason)
+		if err != nil {
+			result = reason + " failed: " + err.Error()
+		} else {
+			result = reason
+		}
+	}
+
+	r.log.Info("machine remediation", "machine", m.ID, "result", result)
+
+	var project string
+	if m.Allocation != nil {
+		project = m.Allocation.Project
+	}
+
+	r.auditEvent(auditing.Entry{
+		User:    machineRemediationIssuer,
+		Project: project,
+		Path:    string(task.TypeMachineRemediation),
+		Body: map[string]any{
+			"machine":   m.ID,
+			"partition": m.PartitionID,
+			"trigger":   trigger,
+			"count":     payload.Count,
+			"action":    action,
+			"result":    result,
+		},
+		Error: err,
+	})
+
+	r.UnscopedMachine().AdditionalMethods().archiveTimeline(ctx, newMachineTimelineEntry(m.ID, metal.MachineTimelineRemediation, "", machineRemediationIssuer, result))
+
+	if _, writeErr := t.ResultWriter().Write([]byte(result)); writeErr != nil {
+		r.log.Warn("machine remediation handler could not write result to task result", "error", writeErr)
+	}
+
+	return err
+}
+
+func (r *Store) remediateMachine(ctx context.Context, m *metal.Machine, action metal.RemediationAction, reason string) error {
+	switch action {
+	case metal.RemediationActionPowerCycle:
+		return r.remediationBMCCommand(ctx, m, apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE)
+	case metal.RemediationActionRetryPXEBoot:
+		if err := r.remediationBMCCommand(ctx, m, apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_BOOT_FROM_PXE); err != nil {
+			return err
+		}
+		return r.remediationBMCCommand(ctx, m, apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE)
+	case metal.RemediationActionTaint:
+		return r.UnscopedMachine().AdditionalMethods().changeState(ctx, m, metal.MachineState{
+			Value:       metal.TaintedState,
+			Description: reason,
+			Issuer:      machineRemediationIssuer,
+		})
+	default:
+		return fmt.Errorf("unknown remediation action:%q", action)
+	}
+}
+
+func (r *Store) remediationBMCCommand(ctx context.Context, m *metal.Machine, command apiv2.MachineBMCCommand) error {
+	cmd, err := enum.GetStringValue(command)
+	if err != nil {
+		return err
+	}
+
+	return r.executeMachineBMCCommand(ctx, &task.MachineBMCCommandPayload{
+		UUID:      m.ID,
+		Partition: m.PartitionID,
+		Command:   *cmd,
+		CommandID: machineBMCCommandID(m.ID, *cmd),
+	})
+}
+
+// remediationSkipReason returns why the action must not be taken against the machine, empty if it can be taken.
+// Locked machines are never remediated.
+func remediationSkipReason(m *metal.Machine, action metal.RemediationAction) string {
+	switch {
+	case m.State.Value == metal.LockedState:
+		return "machine is locked"
+	case action == metal.RemediationActionTaint && m.State.Value == metal.TaintedState:
+		return "machine is already tainted"
+	case action != metal.RemediationActionTaint && (m.IPMI.Address == "" || m.IPMI.User == "" || m.IPMI.Password == ""):
+		return "machine does not have bmc connection details yet"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_remediationSkipReason(t *testing.T) {
+	ipmi := metal.IPMI{Address: "10.0.0.1", User: "metal", Password: "secret"}
+
+	tests := []struct {
+		name    string
+		machine *metal.Machine
+		action  metal.RemediationAction
+		want    string
+	}{
+		{
+			name:    "power cycle",
+			machine: &metal.Machine{IPMI: ipmi},
+			action:  metal.RemediationActionPowerCycle,
+		},
+		{
+			name:    "locked",
+			machine: &metal.Machine{IPMI: ipmi, State: metal.MachineState{Value: metal.LockedState}},
+			action:  metal.RemediationActionPowerCycle,
+			want:    "machine is locked",
+		},
+		{
+			name:    "no bmc",
+			machine: &metal.Machine{},
+			action:  metal.RemediationActionRetryPXEBoot,
+			want:    "machine does not have bmc connection details yet",
+		},
+		{
+			name:    "taint without bmc",
+			machine: &metal.Machine{},
+			action:  metal.RemediationActionTaint,
+		},
+		{
+			name:    "already tainted",
+			machine: &metal.Machine{State: metal.MachineState{Value: metal.TaintedState}},
+			action:  metal.RemediationActionTaint,
+			want:    "machine is already tainted",
+		},
+		{
+			name:    "power cycle tainted",
+			machine: &metal.Machine{IPMI: ipmi, State: metal.MachineState{Value: metal.TaintedState}},
+			action:  metal.RemediationActionPowerCycle,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, remediationSkipReason(tt.machine, tt.action))
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/api/go/enum"
+	"github.com/metal-stack/api/go/errorutil"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/robfig/cron/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type (
+	// BMCCommandScheduleRequest executes a bmc command against all machines matched by the query at a future time
+	// or recurring on a cron expression. Exactly one of At and Cron must be given.
+	BMCCommandScheduleRequest struct {
+		BulkBMCCommandRequest
+		// At is the time when the command is executed once
+		At time.Time
+		// Cron is a standard cron expression on which the command is executed recurring, e.g. "0 2 * * 6"
+		Cron string
+	}
+
+	// BMCCommandSchedule is a bmc command which is scheduled for execution.
+	// It is backed by a scheduled task per occurrence, the id of the schedule stays the same for all of them.
+	BMCCommandSchedule struct {
+		ID          string
+		TaskID      string
+		Query       *apiv2.MachineQuery
+		Command     string
+		Concurrency int
+		Interval    time.Duration
+		Cron        string
+		NextRun     time.Time
+	}
+)
+
+// ScheduleBMCCommand schedules a bulk bmc command and returns the id of the schedule.
+// Recurring commands enqueue their next occurrence when they are executed, all occurrences share the id of the schedule.
+func (r *machineRepository) ScheduleBMCCommand(ctx context.Context, req *BMCCommandScheduleRequest) (string, error) {
+	if req.At.IsZero() == (req.Cron == "") {
+		return "", errorutil.InvalidArgument("either a time or a cron expression must be given")
+	}
+
+	cmd, err := validateBulkBMCCommandRequest(&req.BulkBMCCommandRequest)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	at := req.At
+	if req.Cron != "" {
+		at, err = nextCronRun(req.Cron, now)
+		if err != nil {
+			return "", errorutil.InvalidArgument("invalid cron expression %q: %s", req.Cron, err)
+		}
+	}
+	if !at.After(now) {
+		return "", errorutil.InvalidArgument("the command must be scheduled in the future")
+	}
+
+	query, err := protojson.Marshal(req.Query)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode machine query: %w", err)
+	}
+
+	scheduleID := uuid.NewString()
+
+	_, err = r.scheduleBMCCommand(&task.MachineScheduledBMCCommandPayload{
+		ScheduleID:  scheduleID,
+		Query:       query,
+		Command:     cmd,
+		Concurrency: req.Concurrency,
+		Interval:    req.Interval,
+		Cron:        req.Cron,
+	}, at)
+	if err != nil {
+		return "", err
+	}
+
+	return scheduleID, nil
+}
+
+// ListBMCCommandSchedules returns all bmc commands which are scheduled for execution, ordered by their next run.
+func (r *machineRepository) ListBMCCommandSchedules() ([]*BMCCommandSchedule, error) {
+	queue := bmcCommandQueue
+	tasks, err := r.s.task.List(&queue)
+	if err != nil {
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []*BMCCommandSchedule
+	for _, info := range tasks {
+		if info.Type != string(task.TypeMachineScheduledBMCCommand) || info.State != asynq.TaskStateScheduled {
+			continue
+		}
+
+		schedule, err := toBMCCommandSchedule(info)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, schedule)
+	}
+
+	slices.SortFunc(result, func(a, b *BMCCommandSchedule) int {
+		return cmp.Or(a.NextRun.Compare(b.NextRun), cmp.Compare(a.ID, b.ID))
+	})
+
+	return result, nil
+}
+
+// CancelBMCCommandSchedule removes the scheduled occurrence of a bmc command schedule, recurring commands are not executed anymore.
+// An occurrence which is already running is not stopped.
+func (r *machineRepository) CancelBMCCommandSchedule(scheduleID string) error {
+	queue := bmcCommandQueue
+	tasks, err := r.s.task.List(&queue)
+	if err != nil && !errors.Is(err, asynq.ErrQueueNotFound) {
+		return err
+	}
+
+	found := false
+	for _, info := range tasks {
+		if info.Type != string(task.TypeMachineScheduledBMCCommand) || (info.State != asynq.TaskStateScheduled && info.State != asynq.TaskStatePending) {
+			continue
+		}
+
+		payload, err := task.DecodePayload[*task.MachineScheduledBMCCommandPayload](info.Payload)
+		if err != nil {
+			return err
+		}
+		if payload.ScheduleID != scheduleID {
+			continue
+		}
+
+		err = r.s.task.DeleteTask(bmcCommandQueue, info.ID)
+		if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+			return err
+		}
+
+		found = true
+	}
+
+	if !found {
+		return errorutil.NotFound("bmc command schedule %q not found", scheduleID)
+	}
+
+	return nil
+}
+
+// scheduleBMCCommand enqueues an occurrence of a bmc command schedule. The id of the task is derived from the schedule
+// and the time of the occurrence, such that an occurrence which is enqueued twice is only executed once.
+func (r *machineRepository) scheduleBMCCommand(payload *task.MachineScheduledBMCCommandPayload, at time.Time) (string, error) {
+	taskID := bmcCommandScheduleTaskID(payload.ScheduleID, at)
+
+	info, err := r.s.task.NewTask(payload,
+		asynq.TaskID(taskID),
+		asynq.ProcessAt(at),
+		// a failed occurrence is not repeated, the next occurrence of a recurring command is executed on schedule
+		asynq.MaxRetry(0),
+	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		r.s.log.Info("machine bmc command already scheduled", "schedule", payload.ScheduleID, "task", taskID, "at", at)
+		return taskID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	r.s.log.Info("machine bmc command scheduled", "schedule", payload.ScheduleID, "task", info.ID, "command", payload.Command, "at", at, "cron", payload.Cron)
+
+	return info.ID, nil
+}
+
+// bmcCommandScheduleTaskID returns the id of the task of a bmc command schedule which is executed at the given time.
+func bmcCommandScheduleTaskID(scheduleID string, at time.Time) string {
+	return fmt.Sprintf("bmc-command-schedule:%s:%d", scheduleID, at.Unix())
+}
+
+func (r *Store) MachineScheduledBMCCommandHandleFn(ctx context.Context, t *asynq.Task) error {
+	payload, err := task.DecodePayload[*task.MachineScheduledBMCCommandPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	r.log.Info("machine scheduled bmc command handler", "schedule", payload.ScheduleID, "command", payload.Command, "cron", payload.Cron)
+
+	if payload.Cron != "" {
+		// the next occurrence is enqueued first, such that a failing command does not stop the schedule
+		next, err := nextCronRun(payload.Cron, time.Now())
+		if err != nil {
+			return fmt.Errorf("%w: invalid cron expression %q: %w", asynq.SkipRetry, payload.Cron, err)
+		}
+
+		nextID, err := r.UnscopedMachine().AdditionalMethods().scheduleBMCCommand(payload, next)
+		if err != nil {
+			return fmt.Errorf("unable to schedule next occurrence of bmc command: %w", err)
+		}
+
+		r.log.Info("machine scheduled bmc command next occurrence", "task", nextID, "at", next)
+	}
+
+	var query apiv2.MachineQuery
+	err = protojson.Unmarshal(payload.Query, &query)
+	if err != nil {
+		return fmt.Errorf("%w: unable to decode machine query: %w", asynq.SkipRetry, err)
+	}
+
+	command, err := enum.GetEnum[apiv2.MachineBMCCommand](payload.Command)
+	if err != nil {
+		return fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+	}
+
+	// the machines are selected when the command is executed, locked machines are skipped by the bulk bmc command
+	taskID, err := r.UnscopedMachine().AdditionalMethods().BulkBMCCommand(ctx, &BulkBMCCommandRequest{
+		Query:       &query,
+		Command:     command,
+		Concurrency: payload.Concurrency,
+		Interval:    payload.Interval,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, writeErr := t.ResultWriter().Write([]byte(taskID)); writeErr != nil {
+		r.log.Warn("machine scheduled bmc command handler could not write bulk bmc command task id to task result", "error", writeErr)
+	}
+
+	return nil
+}
+
+func toBMCCommandSchedule(info *asynq.TaskInfo) (*BMCCommandSchedule, error) {
+	payload, err := task.DecodePayload[*task.MachineScheduledBMCCommandPayload](info.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &apiv2.MachineQuery{}
+	err = protojson.Unmarshal(payload.Query, query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode machine query of task %q: %w", info.ID, err)
+	}
+
+	return &BMCCommandSchedule{
+		ID:          payload.ScheduleID,
+		TaskID:      info.ID,
+		Query:       query,
+		Command:     payload.Command,
+		Concurrency: payload.Concurrency,
+		Interval:    payload.Interval,
+		Cron:        payload.Cron,
+		NextRun:     info.NextProcessAt,
+	}, nil
+}
+
+// nextCronRun returns the next time after now on which the standard cron expression is due.
+func nextCronRun(expression string, now time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return time.Time{}, errors.New("cron expression is never due")
+	}
+
+	return next, nil
+}
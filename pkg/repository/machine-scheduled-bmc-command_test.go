@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_nextCronRun(t *testing.T) {
+	// a friday
+	now := time.Date(2025, 6, 6, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		expression string
+		want       time.Time
+		wantErr    bool
+	}{
+		{
+			name:       "every night at two",
+			expression: "0 2 * * *",
+			want:       time.Date(2025, 6, 7, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "saturday night",
+			expression: "30 1 * * 6",
+			want:       time.Date(2025, 6, 7, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:       "descriptor",
+			expression: "@hourly",
+			want:       time.Date(2025, 6, 6, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "invalid expression",
+			expression: "every night",
+			wantErr:    true,
+		},
+		{
+			name:       "never due",
+			expression: "0 0 30 2 *",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextCronRun(tt.expression, now)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_bmcCommandScheduleTaskID(t *testing.T) {
+	at := time.Date(2025, 6, 7, 2, 0, 0, 0, time.UTC)
+
+	// an occurrence which is enqueued twice, e.g. by a retried handler, must get the same id
+	require.Equal(t, bmcCommandScheduleTaskID("s1", at), bmcCommandScheduleTaskID("s1", at))
+	require.NotEqual(t, bmcCommandScheduleTaskID("s1", at), bmcCommandScheduleTaskID("s1", at.Add(7*24*time.Hour)))
+	require.NotEqual(t, bmcCommandScheduleTaskID("s1", at), bmcCommandScheduleTaskID("s2", at))
+}
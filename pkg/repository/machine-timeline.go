@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/db/queries"
+)
+
+// archiveTimeline stores entries in the machine timeline. Archiving is best effort, the lifecycle operation which
+// produced the entries must not fail because of it.
+func (r *machineRepository) archiveTimeline(ctx context.Context, entries ...*metal.MachineTimelineEntry) {
+	for _, e := range entries {
+		if err := r.s.ds.MachineTimeline().Upsert(ctx, e); err != nil {
+			r.s.log.Warn("unable to archive machine timeline entry", "machine", e.Machine, "type", e.Type, "error", err)
+		}
+	}
+}
+
+// MachineTimelineCleanupHandleFn deletes the machine timeline entries which exceed the timeline retention.
+func (r *Store) MachineTimelineCleanupHandleFn(ctx context.Context, t *asynq.Task) error {
+	_, err := task.DecodePayload[*task.MachineTimelineCleanupPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	if r.timeline.Retention <= 0 {
+		return nil
+	}
+
+	deleted, err := r.ds.MachineTimeline().DeleteAll(ctx, queries.MachineTimelineExpired(time.Now().Add(-r.timeline.Retention)))
+	if err != nil {
+		return fmt.Errorf("unable to delete expired machine timeline entries: %w", err)
+	}
+
+	r.log.Info("deleted expired machine timeline entries", "count", deleted)
+
+	return nil
+}
+
+func newMachineTimelineEntry(machineID string, t metal.MachineTimelineEntryType, project, issuer, message string) *metal.MachineTimelineEntry {
+	now := time.Now()
+
+	return &metal.MachineTimelineEntry{
+		Base: metal.Base{
+			ID: metal.MachineTimelineEntryID(machineID, t, now),
+		},
+		Machine: machineID,
+		Time:    now,
+		Type:    t,
+		Project: project,
+		Issuer:  issuer,
+		Message: message,
+	}
+}
+
+func stateChangeMessage(state metal.MachineState) string {
+	value := string(state.Value)
+	if state.Value == metal.AvailableState {
+		// the available state is stored as empty string
+		value = "AVAILABLE"
+	}
+	return strings.TrimSpace(value + " " + state.Description)
+}
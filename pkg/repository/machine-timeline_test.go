@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MachineTimelineCleanupHandleFn(t *testing.T) {
+	t.Parallel()
+
+	const m1 = "00000000-0000-0000-0000-000000000001"
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log, test.WithMachineTimelineRetention(24*time.Hour))
+	defer closer()
+
+	ctx := t.Context()
+
+	var (
+		expired = time.Now().Add(-48 * time.Hour)
+		recent  = time.Now().Add(-time.Hour)
+	)
+
+	for _, at := range []time.Time{expired, recent} {
+		_, err := testStore.GetDatastore().MachineTimeline().Create(ctx, &metal.MachineTimelineEntry{
+			Base:    metal.Base{ID: metal.MachineTimelineEntryID(m1, metal.MachineTimelineAllocation, at)},
+			Machine: m1,
+			Time:    at,
+			Type:    metal.MachineTimelineAllocation,
+		})
+		require.NoError(t, err)
+	}
+
+	payload, err := task.EncodePayload(&task.MachineTimelineCleanupPayload{})
+	require.NoError(t, err)
+
+	require.NoError(t, testStore.MachineTimelineCleanupHandleFn(ctx, asynq.NewTask(string(task.TypeMachineTimelineCleanup), payload)))
+
+	entries, err := testStore.GetDatastore().MachineTimeline().List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.WithinDuration(t, recent, entries[0].Time, time.Second)
+}
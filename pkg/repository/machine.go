@@ -90,7 +90,7 @@ func (r *machineRepository) SendEvent(ctx context.Context, machineID string, eve
 		return errorutil.InvalidArgument("event for machine %s is nil", machineID)
 	}
 
-	_, err := r.get(ctx, machineID)
+	m, err := r.get(ctx, machineID)
 	if err != nil && !errorutil.IsNotFound(err) {
 		return err
 	}
@@ -140,9 +140,21 @@ func (r *machineRepository) SendEvent(ctx context.Context, machineID string, eve
 	if err = newEC.Validate(); err != nil {
 		return err
 	}
-	newEC.TrimEvents(100)
+	evicted := newEC.TrimEvents(100)
+	r.archiveTimeline(ctx, evicted.TimelineEntries(machineID)...)
 
-	return r.s.ds.Event().Upsert(ctx, newEC)
+	trigger, count := newEC.CountRemediationTriggers(ec, ev)
+
+	err = r.s.ds.Event().Upsert(ctx, newEC)
+	if err != nil {
+		return err
+	}
+
+	if trigger != "" && m != nil {
+		r.remediate(ctx, m, trigger, count)
+	}
+
+	return nil
 }
 
 func (r *machineRepository) get(ctx context.Context, id string) (*metal.Machine, error) {
@@ -172,6 +184,8 @@ func (r *machineRepository) create(ctx context.Context, req *apiv2.MachineServic
 
 	machine := result.machine
 
+	r.archiveTimeline(ctx, newMachineTimelineEntry(machine.ID, metal.MachineTimelineAllocation, machine.Allocation.Project, machine.Allocation.Creator, machine.Allocation.Name))
+
 	err = r.s.queue.PushMachineAllocation(ctx, machine.ID, task.MachineAllocationPayload{UUID: machine.Allocation.UUID})
 	if err != nil {
 		return nil, err
@@ -830,6 +844,23 @@ func (r *machineRepository) Register(ctx context.Context, req *infrav2.BootServi
 		}
 	}
 
+	bios := metal.BIOS{
+		Version: req.Bios.Version,
+		Vendor:  req.Bios.Vendor,
+		Date:    req.Bios.Date,
+	}
+
+	var hardwareChanges []metal.HardwareChange
+	if m != nil {
+		hardwareChanges = metal.DiffHardware(m.Hardware, m.BIOS, machineHardware, bios)
+	}
+
+	// the hardware history is best effort, the registration must not fail because of it
+	err = r.recordHardwareSnapshot(ctx, req.Uuid, machineHardware, bios, hardwareChanges)
+	if err != nil {
+		r.s.log.Error("unable to record hardware snapshot", "machine", req.Uuid, "error", err)
+	}
+
 	if m == nil {
 		// machine is not in the database, create it
 		m = &metal.Machine{
@@ -839,11 +870,7 @@ func (r *machineRepository) Register(ctx context.Context, req *infrav2.BootServi
 			Allocation: nil,
 			SizeID:     size.ID,
 			Hardware:   machineHardware,
-			BIOS: metal.BIOS{
-				Version: req.Bios.Version,
-				Vendor:  req.Bios.Vendor,
-				Date:    req.Bios.Date,
-			},
+			BIOS:       bios,
 			State: metal.MachineState{
 				Value:              metal.AvailableState,
 				MetalHammerVersion: req.MetalHammerVersion,
@@ -865,9 +892,8 @@ func (r *machineRepository) Register(ctx context.Context, req *infrav2.BootServi
 		// machine has already registered, update it
 		m.SizeID = size.ID
 		m.Hardware = machineHardware
-		m.BIOS.Version = req.Bios.Version
-		m.BIOS.Vendor = req.Bios.Vendor
-		m.BIOS.Date = req.Bios.Date
+		m.BIOS = bios
+		m.HardwareChanges = append(m.HardwareChanges, hardwareChanges...)
 		m.IPMI = ipmi
 		m.State.MetalHammerVersion = req.MetalHammerVersion
 		m.PartitionID = req.Partition
@@ -1292,6 +1318,9 @@ func (r *machineRepository) convertToBMCReport(machine *metal.Machine) *apiv2.Ma
 	return bmcReport
 }
 
+// machineBMCCommandTimeout is the duration metal-bmc has to execute a bmc command
+const machineBMCCommandTimeout = time.Minute
+
 func (r *machineRepository) MachineBMCCommand(ctx context.Context, machineUUID, partition string, command apiv2.MachineBMCCommand) (string, error) {
 	cmdString, err := enum.GetStringValue(command)
 	if err != nil {
@@ -1299,7 +1328,7 @@ func (r *machineRepository) MachineBMCCommand(ctx context.Context, machineUUID,
 	}
 
 	cmd := *cmdString
-	commandId := machineUUID + ":machine-bmc-command:" + cmd
+	commandId := machineBMCCommandID(machineUUID, cmd)
 
 	info, err := r.s.task.NewTask(&task.MachineBMCCommandPayload{
 		UUID:      machineUUID,
@@ -1307,7 +1336,7 @@ func (r *machineRepository) MachineBMCCommand(ctx context.Context, machineUUID,
 		Command:   cmd,
 		CommandID: commandId,
 	},
-		asynq.Timeout(time.Minute),
+		asynq.Timeout(machineBMCCommandTimeout),
 		asynq.MaxRetry(0),
 	)
 	if err != nil {
@@ -1417,7 +1446,7 @@ func (r *machineRepository) WaitForBMCCommand(ctx context.Context, req *infrav2.
 				return errorutil.Internal("unable to send response into stream %w", err)
 			}
 
-			r.s.log.Debug("waitformachineevent sent to stream", "response", resp)
+			r.s.log.Debug("waitformachineevent sent to stream", "machine", resp.Uuid, "command", resp.BmcCommand, "command-id", resp.CommandId)
 		}
 	}
 }
@@ -1452,21 +1481,24 @@ func (r *machineRepository) SetState(ctx context.Context, req *adminv2.MachineSe
 	if ok {
 		issuer = tok.User
 	}
-	ms.State.Description = req.Description
-	ms.State.Issuer = issuer
 
+	var value metal.MState
 	switch req.State {
 	case apiv2.MachineState_MACHINE_STATE_LOCKED:
-		ms.State.Value = metal.LockedState
+		value = metal.LockedState
 	case apiv2.MachineState_MACHINE_STATE_TAINTED:
-		ms.State.Value = metal.TaintedState
+		value = metal.TaintedState
 	case apiv2.MachineState_MACHINE_STATE_AVAILABLE:
-		ms.State.Value = metal.AvailableState
+		value = metal.AvailableState
 	default:
 		return nil, errorutil.InvalidArgument("given state %q is not supported", req.State)
 	}
 
-	err = r.s.ds.Machine().Update(ctx, ms)
+	err = r.changeState(ctx, ms, metal.MachineState{
+		Value:       value,
+		Description: req.Description,
+		Issuer:      issuer,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1481,6 +1513,20 @@ func (r *machineRepository) SetState(ctx context.Context, req *adminv2.MachineSe
 	}, nil
 }
 
+func (r *machineRepository) changeState(ctx context.Context, ms *metal.Machine, state metal.MachineState) error {
+	state.Changed = time.Now()
+	ms.ChangeState(state)
+
+	err := r.s.ds.Machine().Update(ctx, ms)
+	if err != nil {
+		return err
+	}
+
+	r.archiveTimeline(ctx, newMachineTimelineEntry(ms.ID, metal.MachineTimelineStateChange, "", state.Issuer, stateChangeMessage(ms.State)))
+
+	return nil
+}
+
 func (r *machineRepository) Issues(ctx context.Context, req *adminv2.MachineServiceIssuesRequest) (*adminv2.MachineServiceIssuesResponse, error) {
 
 	var (
@@ -1575,6 +1621,10 @@ func (r *machineRepository) Issues(ctx context.Context, req *adminv2.MachineServ
 		}
 		for _, issue := range machineWithIssues.Issues {
 			issueType, err := issues.ToAPIV2Type(issue.Type)
+			if errors.Is(err, issues.ErrNoAPIV2Type) {
+				// the issue can not be returned without a distinct type
+				continue
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -1598,6 +1648,10 @@ func (r *machineRepository) Issues(ctx context.Context, req *adminv2.MachineServ
 			})
 		}
 
+		if len(entry.Issues) == 0 {
+			continue
+		}
+
 		allIssues = append(allIssues, entry)
 	}
 
@@ -1693,25 +1747,48 @@ func (r *Store) MachineBMCCommandHandleFn(ctx context.Context, t *asynq.Task) er
 
 	r.log.Info("machine bmc command handler", "machine", payload.UUID, "command", payload.Command)
 
+	err = r.executeMachineBMCCommand(ctx, payload)
+	if err != nil {
+		if _, writeErr := t.ResultWriter().Write([]byte(err.Error())); writeErr != nil {
+			r.log.Warn("machine bmc command handler could not command execution error to task result", "error", writeErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// executeMachineBMCCommand pushes the bmc command to the metal-bmc of the partition and waits until it reports
+// the command as done, at most for the bmc command timeout.
+func (r *Store) executeMachineBMCCommand(ctx context.Context, payload *task.MachineBMCCommandPayload) error {
+	ctx, cancel := context.WithTimeout(ctx, machineBMCCommandTimeout)
+	defer cancel()
+
 	if err := r.queue.PushMachineCommand(ctx, payload.Partition, payload); err != nil {
 		return err
 	}
 
 	select {
-	case result := <-r.queue.WaitMachineCommandDone(ctx, payload.CommandID):
-		r.log.Debug("machine bmc command handler done received", "result", result)
+	case result, ok := <-r.queue.WaitMachineCommandDone(ctx, payload.CommandID):
+		if !ok {
+			return fmt.Errorf("bmc command %s was not done in time: %w", payload.CommandID, ctx.Err())
+		}
+		r.log.Debug("machine bmc command done received", "machine", payload.UUID, "result", result)
 		if result.Error != nil {
-			if _, writeErr := t.ResultWriter().Write([]byte(*result.Error)); writeErr != nil {
-				r.log.Warn("machine bmc command handler could not command execution error to task result", "error", writeErr)
-			}
 			return errors.New(*result.Error)
 		}
 		return nil
 	case <-ctx.Done():
-		return ctx.Err()
+		return fmt.Errorf("bmc command %s was not done in time: %w", payload.CommandID, ctx.Err())
 	}
 }
 
+// machineBMCCommandID returns the id metal-bmc reports the completion of a bmc command with. It is unique per command,
+// otherwise the completion of a concurrent command of the same kind against the same machine would be taken for this one.
+func machineBMCCommandID(machineUUID, command string) string {
+	return machineUUID + ":machine-bmc-command:" + command + ":" + uuid.NewString()
+}
+
 func (r *machineRepository) scopedMachineFilters(filter generic.EntityQuery) []generic.EntityQuery {
 	var qs []generic.EntityQuery
 
@@ -1783,6 +1860,11 @@ func (r *machineRepository) releaseAllocationTask(ctx context.Context, payload *
 		return fmt.Errorf("unable to find machine: %w", err)
 	}
 
+	var (
+		project = pointer.SafeDeref(m.Allocation).Project
+		name    = pointer.SafeDeref(m.Allocation).Name
+	)
+
 	m.Allocation = nil
 	m.PreAllocated = false
 
@@ -1790,6 +1872,8 @@ func (r *machineRepository) releaseAllocationTask(ctx context.Context, payload *
 		return fmt.Errorf("unable to remove machine allocation: %w", err)
 	}
 
+	r.archiveTimeline(ctx, newMachineTimelineEntry(m.ID, metal.MachineTimelineRelease, project, "", name))
+
 	r.s.log.Debug("machine delete removed allocation", "allocation-uuid", payload.AllocationUUID)
 
 	return nil
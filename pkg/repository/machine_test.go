@@ -0,0 +1,190 @@
+package repository_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/queue"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/metal-stack/metal-apiserver/pkg/token"
+	"github.com/metal-stack/metal-lib/auditing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MachineBMCCommandHandleFn(t *testing.T) {
+	t.Parallel()
+
+	const (
+		partition = "partition-1"
+		m1        = "00000000-0000-0000-0000-000000000001"
+	)
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{Base: metal.Base{ID: m1}, PartitionID: partition, IPMI: metal.IPMI{Address: "192.168.0.1:623", User: "metal", Password: "secret"}},
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var (
+		mu         sync.Mutex
+		commandIDs []string
+	)
+
+	// metal-bmc is simulated directly on the queue, the first command fails
+	q := queue.New(log, testStore.GetValkeyClient())
+	go func() {
+		for cmd := range q.WaitMachineCommand(ctx, partition) {
+			mu.Lock()
+			commandIDs = append(commandIDs, cmd.CommandID)
+			first := len(commandIDs) == 1
+			mu.Unlock()
+
+			done := task.BMCCommandDonePayload{}
+			if first {
+				done.Error = new("bmc not reachable")
+			}
+			_ = q.PushMachineCommandDone(ctx, cmd.CommandID, done)
+		}
+	}()
+
+	// two commands of the same kind against the same machine must not take the completion of each other
+	first, err := testStore.UnscopedMachine().AdditionalMethods().MachineBMCCommand(ctx, m1, partition, apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE)
+	require.NoError(t, err)
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		info, err := testStore.Task().GetTaskInfo("default", first)
+		require.NoError(c, err)
+		require.Equal(c, asynq.TaskStateArchived, info.State)
+		require.Equal(c, "bmc not reachable", string(info.Result))
+	}, 10*time.Second, 100*time.Millisecond)
+
+	second, err := testStore.UnscopedMachine().AdditionalMethods().MachineBMCCommand(ctx, m1, partition, apiv2.MachineBMCCommand_MACHINE_BMC_COMMAND_CYCLE)
+	require.NoError(t, err)
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		info, err := testStore.Task().GetTaskInfo("default", second)
+		require.NoError(c, err)
+		require.Equal(c, asynq.TaskStateCompleted, info.State)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, commandIDs, 2)
+	require.NotEqual(t, commandIDs[0], commandIDs[1])
+}
+
+func Test_SetState(t *testing.T) {
+	t.Parallel()
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{Base: metal.Base{ID: "m1"}},
+	})
+
+	machines := testStore.UnscopedMachine().AdditionalMethods()
+
+	_, err := machines.SetState(token.ContextWithToken(t.Context(), &apiv2.Token{User: "alice"}), &adminv2.MachineServiceSetStateRequest{
+		Uuid:        "m1",
+		State:       apiv2.MachineState_MACHINE_STATE_LOCKED,
+		Description: "debugging",
+	})
+	require.NoError(t, err)
+
+	_, err = machines.SetState(token.ContextWithToken(t.Context(), &apiv2.Token{User: "bob"}), &adminv2.MachineServiceSetStateRequest{
+		Uuid:  "m1",
+		State: apiv2.MachineState_MACHINE_STATE_AVAILABLE,
+	})
+	require.NoError(t, err)
+
+	m1, err := testStore.GetDatastore().Machine().Get(t.Context(), "m1")
+	require.NoError(t, err)
+	require.Equal(t, metal.AvailableState, m1.State.Value)
+	require.Equal(t, "bob", m1.State.Issuer)
+	require.WithinDuration(t, time.Now(), m1.State.Changed, time.Minute)
+	require.Len(t, m1.StateHistory, 1)
+	require.Equal(t, metal.LockedState, m1.StateHistory[0].Value)
+	require.Equal(t, "alice", m1.StateHistory[0].Issuer)
+	require.Equal(t, "debugging", m1.StateHistory[0].Description)
+}
+
+func Test_SendEvent_Remediation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		m1 = "00000000-0000-0000-0000-000000000001"
+		m2 = "00000000-0000-0000-0000-000000000002"
+	)
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log)
+	defer closer()
+
+	ctx := t.Context()
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{Base: metal.Base{ID: m1}, PartitionID: "partition-1"},
+		{Base: metal.Base{ID: m2}, PartitionID: "partition-2"},
+	})
+
+	_, err := testStore.GetDatastore().MachineRemediationPolicy().Create(ctx, &metal.MachineRemediationPolicy{
+		Base:       metal.Base{ID: "partition-1"},
+		TaintAfter: 1,
+	})
+	require.NoError(t, err)
+
+	// a pxe boot during the installation puts the machine into a crash loop
+	for _, id := range []string{m1, m2} {
+		ec, err := testStore.GetDatastore().Event().Get(ctx, id)
+		require.NoError(t, err)
+		ec.Events = metal.ProvisioningEvents{{Time: time.Now(), Event: metal.ProvisioningEventInstalling}}
+		require.NoError(t, testStore.GetDatastore().Event().Update(ctx, ec))
+
+		err = testStore.UnscopedMachine().AdditionalMethods().SendEvent(ctx, id, &apiv2.MachineProvisioningEvent{
+			Event: apiv2.MachineProvisioningEventType_MACHINE_PROVISIONING_EVENT_TYPE_PXE_BOOTING,
+		})
+		require.NoError(t, err)
+	}
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		info, err := testStore.Task().GetTaskInfo("default", "machine-remediation:"+m1)
+		require.NoError(c, err)
+		require.Equal(c, asynq.TaskStateCompleted, info.State)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// partition-2 has no remediation policy, nothing must be enqueued
+	_, err = testStore.Task().GetTaskInfo("default", "machine-remediation:"+m2)
+	require.ErrorIs(t, err, asynq.ErrTaskNotFound)
+
+	machine, err := testStore.GetDatastore().Machine().Get(ctx, m1)
+	require.NoError(t, err)
+	require.Equal(t, metal.TaintedState, machine.State.Value)
+	require.Equal(t, "taint after 1 crashloop in a row", machine.State.Description)
+
+	entries, err := testStore.GetAuditBackend().Search(ctx, auditing.EntryFilter{
+		From: time.Now().Add(-time.Hour),
+		To:   time.Now().Add(time.Hour),
+		Path: string(task.TypeMachineRemediation),
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "machine remediation", entries[0].User)
+	require.Equal(t, auditing.EntryTypeEvent, entries[0].Type)
+}
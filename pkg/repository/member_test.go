@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/repository"
+	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func Test_RemoveMemberRevokesAccess(t *testing.T) {
+	t.Parallel()
+
+	log := slog.Default()
+
+	testStore, closer := test.StartRepositoryWithCleanup(t, log, test.WithPostgres(true))
+	defer closer()
+
+	test.CreateTenants(t, testStore, []*apiv2.TenantServiceCreateRequest{
+		{Name: "john.doe@github.com"},
+		{Name: "will.smith@github.com"},
+	})
+	test.CreateTenantMemberships(t, testStore, "john.doe@github.com", []*api.TenantMemberCreateRequest{
+		{MemberID: "john.doe@github.com", Role: apiv2.TenantRole_TENANT_ROLE_OWNER},
+		{MemberID: "will.smith@github.com", Role: apiv2.TenantRole_TENANT_ROLE_VIEWER},
+	})
+	projectMap := test.CreateProjects(t, testStore, []*apiv2.ProjectServiceCreateRequest{
+		{Login: "john.doe@github.com"},
+	})
+	project := projectMap["john.doe@github.com"]
+	test.CreateProjectMemberships(t, testStore, project, []*api.ProjectMemberCreateRequest{
+		{TenantId: "john.doe@github.com", Role: apiv2.ProjectRole_PROJECT_ROLE_OWNER},
+		{TenantId: "will.smith@github.com", Role: apiv2.ProjectRole_PROJECT_ROLE_EDITOR},
+	})
+
+	tok := testStore.GetToken("will.smith@github.com", &apiv2.TokenServiceCreateRequest{
+		Expires:      durationpb.New(time.Hour),
+		ProjectRoles: map[string]apiv2.ProjectRole{project: apiv2.ProjectRole_PROJECT_ROLE_EDITOR},
+		TenantRoles:  map[string]apiv2.TenantRole{"john.doe@github.com": apiv2.TenantRole_TENANT_ROLE_VIEWER},
+	})
+
+	ctx := t.Context()
+
+	removed, err := testStore.Project(project).AdditionalMethods().Member().Delete(ctx, "will.smith@github.com")
+	require.NoError(t, err)
+	require.Equal(t, "will.smith@github.com", removed.Id)
+
+	revokeAccess(t, testStore.Store, &task.AccessRevokePayload{Project: project, User: "will.smith@github.com"})
+
+	got, err := testStore.GetTokenStore().Get(ctx, "will.smith@github.com", tok.Uuid)
+	require.NoError(t, err)
+	require.Empty(t, got.ProjectRoles)
+	require.Equal(t, apiv2.TenantRole_TENANT_ROLE_VIEWER, got.TenantRoles["john.doe@github.com"])
+
+	_, err = testStore.Tenant().AdditionalMethods().Member("john.doe@github.com").Delete(ctx, "will.smith@github.com")
+	require.NoError(t, err)
+
+	revokeAccess(t, testStore.Store, &task.AccessRevokePayload{Tenant: "john.doe@github.com", User: "will.smith@github.com"})
+
+	// the token does not grant any access anymore and is therefore revoked
+	tokens, err := testStore.GetTokenStore().List(ctx, "will.smith@github.com")
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
+
+func revokeAccess(t *testing.T, s *repository.Store, payload *task.AccessRevokePayload) {
+	encoded, err := json.Marshal(payload)
+	require.NoError(t, err)
+	require.NoError(t, s.AccessRevokeHandleFn(t.Context(), asynq.NewTask(string(task.TypeAccessRevoke), encoded)))
+}
@@ -0,0 +1,347 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/api/go/errorutil"
+	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/db/queries"
+)
+
+const capacityLowEventType = "partition.capacity.low"
+
+type (
+	// LowCapacity is a size in a partition whose free machines dropped below its watermark.
+	LowCapacity struct {
+		Partition   string
+		Size        string
+		Free        int64
+		MinimumFree int64
+		Taken       time.Time
+	}
+
+	// CapacityWatermark declares how many free machines of a size must be left before capacity is reported as low.
+	// A watermark without partition applies to all partitions which do not have a watermark of their own.
+	CapacityWatermark struct {
+		Size        string
+		Partition   string
+		MinimumFree int64
+	}
+
+	// CapacityEvent is sent to the capacity webhook.
+	CapacityEvent struct {
+		Type        string    `json:"type"`
+		Partition   string    `json:"partition"`
+		Size        string    `json:"size"`
+		Free        int64     `json:"free"`
+		MinimumFree int64     `json:"minimum_free"`
+		Time        time.Time `json:"time"`
+	}
+)
+
+// snapshot stores the current capacity of every size in every partition.
+func (p *partitionRepository) snapshot(ctx context.Context) ([]*metal.PartitionCapacitySnapshot, error) {
+	current, err := p.currentCapacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*metal.PartitionCapacitySnapshot
+	for _, c := range current {
+		snapshot, err := p.s.ds.PartitionCapacitySnapshot().Create(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("unable to store capacity snapshot of size %s in partition %s: %w", c.Size, c.Partition, err)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// currentCapacity returns the current capacity of every size in every partition without storing it.
+func (p *partitionRepository) currentCapacity(ctx context.Context) ([]*metal.PartitionCapacitySnapshot, error) {
+	resp, err := p.Capacity(ctx, &adminv2.PartitionServiceCapacityRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		taken   = time.Now()
+		current []*metal.PartitionCapacitySnapshot
+	)
+
+	for _, pc := range resp.PartitionCapacity {
+		for _, c := range pc.MachineSizeCapacities {
+			current = append(current, &metal.PartitionCapacitySnapshot{
+				Partition:    pc.Partition,
+				Size:         c.Size,
+				Taken:        taken,
+				Total:        c.Total,
+				Free:         c.Free,
+				Allocated:    c.Allocated,
+				Reservations: c.Reservations,
+				Faulty:       c.Faulty,
+			})
+		}
+	}
+
+	return current, nil
+}
+
+// LowCapacity returns the sizes per partition whose free machines were below their watermark in the latest snapshot.
+// The capacity is calculated by the snapshot task only, such that reading it stays cheap.
+func (p *partitionRepository) LowCapacity(ctx context.Context) ([]*LowCapacity, error) {
+	latest, err := p.latestSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return lowCapacity(latest, p.s.capacity.Watermarks), nil
+}
+
+// latestSnapshots returns the latest capacity snapshot of every size in every partition.
+func (p *partitionRepository) latestSnapshots(ctx context.Context) ([]*metal.PartitionCapacitySnapshot, error) {
+	latest, err := p.s.ds.PartitionCapacitySnapshot().List(ctx, queries.PartitionCapacitySnapshotFilter(time.Time{}, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) == 0 {
+		return nil, nil
+	}
+
+	// all sizes of all partitions are snapshotted at the same time
+	return p.s.ds.PartitionCapacitySnapshot().List(ctx, queries.PartitionCapacitySnapshotFilter(latest[0].Taken, 0))
+}
+
+// PartitionCapacitySnapshotHandleFn snapshots the capacity of all partitions, deletes snapshots exceeding the retention
+// and enqueues an event for the capacity webhook for every size whose free machines dropped below its watermark.
+func (r *Store) PartitionCapacitySnapshotHandleFn(ctx context.Context, t *asynq.Task) error {
+	_, err := task.DecodePayload[*task.PartitionCapacitySnapshotPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	repo := &partitionRepository{s: r}
+
+	previous, err := repo.latestSnapshots(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to fetch previous capacity snapshots: %w", err)
+	}
+
+	current, err := repo.snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to snapshot partition capacity: %w", err)
+	}
+
+	watermarks := r.capacity.Watermarks
+
+	for _, low := range newlyLowCapacity(lowCapacity(previous, watermarks), lowCapacity(current, watermarks)) {
+		r.log.Warn("capacity dropped below watermark", "partition", low.Partition, "size", low.Size, "free", low.Free, "minimum-free", low.MinimumFree)
+
+		if r.capacity.WebhookURL == "" {
+			continue
+		}
+
+		// the event is delivered by its own task, which is retried until the webhook accepts it.
+		// snapshots taken concurrently within the same interval enqueue the same task only once.
+		taskID := capacityEventTaskID(low, r.capacity.SnapshotInterval)
+		info, err := r.task.NewTask(&task.PartitionCapacityEventPayload{
+			Partition:   low.Partition,
+			Size:        low.Size,
+			Free:        low.Free,
+			MinimumFree: low.MinimumFree,
+			Time:        low.Taken,
+		}, asynq.TaskID(taskID))
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			r.log.Info("capacity event already enqueued", "partition", low.Partition, "size", low.Size, "task", taskID)
+			continue
+		}
+		if err != nil {
+			r.log.Error("unable to enqueue capacity event", "partition", low.Partition, "size", low.Size, "error", err)
+			continue
+		}
+
+		r.log.Info("capacity event enqueued", "partition", low.Partition, "size", low.Size, "task", info.ID)
+	}
+
+	if r.capacity.SnapshotRetention <= 0 {
+		return nil
+	}
+
+	expired, err := r.ds.PartitionCapacitySnapshot().List(ctx, queries.PartitionCapacitySnapshotExpired(time.Now().Add(-r.capacity.SnapshotRetention)))
+	if err != nil {
+		return fmt.Errorf("unable to list expired capacity snapshots: %w", err)
+	}
+
+	for _, snapshot := range expired {
+		err = r.ds.PartitionCapacitySnapshot().Delete(ctx, snapshot)
+		if err != nil && !errorutil.IsNotFound(err) {
+			return fmt.Errorf("unable to delete expired capacity snapshot %s: %w", snapshot.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// PartitionCapacityEventHandleFn posts a capacity event to the capacity webhook. An error is returned if the webhook
+// is not reachable or does not accept the event, the task is then retried.
+func (r *Store) PartitionCapacityEventHandleFn(ctx context.Context, t *asynq.Task) error {
+	payload, err := task.DecodePayload[*task.PartitionCapacityEventPayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	if r.capacity.WebhookURL == "" {
+		r.log.Info("capacity webhook is not configured anymore, dropping capacity event", "partition", payload.Partition, "size", payload.Size)
+		return nil
+	}
+
+	body, err := json.Marshal(&CapacityEvent{
+		Type:        capacityLowEventType,
+		Partition:   payload.Partition,
+		Size:        payload.Size,
+		Free:        payload.Free,
+		MinimumFree: payload.MinimumFree,
+		Time:        payload.Time,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.capacity.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook responded with statuscode:%d", resp.StatusCode)
+	}
+
+	r.log.Info("capacity event delivered", "partition", payload.Partition, "size", payload.Size)
+
+	return nil
+}
+
+// capacityEventTaskID returns the id of the capacity event of a size in a partition for the snapshot interval
+// in which the capacity was taken.
+func capacityEventTaskID(low *LowCapacity, interval time.Duration) string {
+	return fmt.Sprintf("partition-capacity-event:%s:%s:%d", low.Partition, low.Size, low.Taken.Truncate(interval).Unix())
+}
+
+// ParseCapacityWatermarks parses capacity watermarks in the form <size>=<minimum-free> or <size>@<partition>=<minimum-free>.
+func ParseCapacityWatermarks(specs []string) ([]*CapacityWatermark, error) {
+	var watermarks []*CapacityWatermark
+
+	for _, spec := range specs {
+		id, minimumFree, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("capacity watermark %q must be in the form <size>[@<partition>]=<minimum-free>", spec)
+		}
+
+		size, partition, _ := strings.Cut(id, "@")
+		if size == "" {
+			return nil, fmt.Errorf("capacity watermark %q must contain a size", spec)
+		}
+
+		free, err := strconv.ParseInt(minimumFree, 10, 64)
+		if err != nil || free <= 0 {
+			return nil, fmt.Errorf("minimum free machines of capacity watermark %q must be a positive number", spec)
+		}
+
+		if slices.ContainsFunc(watermarks, func(w *CapacityWatermark) bool {
+			return w.Size == size && w.Partition == partition
+		}) {
+			return nil, fmt.Errorf("capacity watermark %q is given more than once", id)
+		}
+
+		watermarks = append(watermarks, &CapacityWatermark{
+			Size:        size,
+			Partition:   partition,
+			MinimumFree: free,
+		})
+	}
+
+	return watermarks, nil
+}
+
+// capacityWatermarkFor returns the watermark of the given size in the given partition,
+// falling back to the watermark of the size without partition.
+func capacityWatermarkFor(watermarks []*CapacityWatermark, partition, size string) *CapacityWatermark {
+	var fallback *CapacityWatermark
+	for _, w := range watermarks {
+		if w.Size != size {
+			continue
+		}
+		if w.Partition == partition {
+			return w
+		}
+		if w.Partition == "" {
+			fallback = w
+		}
+	}
+	return fallback
+}
+
+func lowCapacity(snapshots []*metal.PartitionCapacitySnapshot, watermarks []*CapacityWatermark) []*LowCapacity {
+	var result []*LowCapacity
+	for _, s := range snapshots {
+		watermark := capacityWatermarkFor(watermarks, s.Partition, s.Size)
+		if watermark == nil || s.Free >= watermark.MinimumFree {
+			continue
+		}
+
+		result = append(result, &LowCapacity{
+			Partition:   s.Partition,
+			Size:        s.Size,
+			Free:        s.Free,
+			MinimumFree: watermark.MinimumFree,
+			Taken:       s.Taken,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b *LowCapacity) int {
+		return cmp.Or(cmp.Compare(a.Partition, b.Partition), cmp.Compare(a.Size, b.Size))
+	})
+
+	return result
+}
+
+// newlyLowCapacity returns the sizes which are low now, but were not low before, such that events are only sent once.
+func newlyLowCapacity(previous, current []*LowCapacity) []*LowCapacity {
+	var result []*LowCapacity
+	for _, c := range current {
+		if slices.ContainsFunc(previous, func(p *LowCapacity) bool {
+			return p.Partition == c.Partition && p.Size == c.Size
+		}) {
+			continue
+		}
+		result = append(result, c)
+	}
+	return result
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lowCapacity(t *testing.T) {
+	var (
+		taken = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		watermarks = []*CapacityWatermark{
+			{Size: "c1-large-x86", MinimumFree: 5},
+			{Size: "c1-large-x86", Partition: "fra-equ01", MinimumFree: 2},
+			{Size: "n1-medium-x86", Partition: "fra-equ01", MinimumFree: 3},
+		}
+	)
+
+	tests := []struct {
+		name      string
+		snapshots []*metal.PartitionCapacitySnapshot
+		want      []*LowCapacity
+	}{
+		{
+			name: "partition watermark takes precedence",
+			snapshots: []*metal.PartitionCapacitySnapshot{
+				{Partition: "fra-equ01", Size: "c1-large-x86", Free: 3, Taken: taken},
+				{Partition: "fra-equ02", Size: "c1-large-x86", Free: 3, Taken: taken},
+			},
+			want: []*LowCapacity{
+				{Partition: "fra-equ02", Size: "c1-large-x86", Free: 3, MinimumFree: 5, Taken: taken},
+			},
+		},
+		{
+			name: "sizes without watermark are never low",
+			snapshots: []*metal.PartitionCapacitySnapshot{
+				{Partition: "fra-equ01", Size: "c2-xlarge-x86", Free: 0, Taken: taken},
+				{Partition: "fra-equ02", Size: "n1-medium-x86", Free: 0, Taken: taken},
+			},
+			want: nil,
+		},
+		{
+			name: "free machines equal to the watermark are not low",
+			snapshots: []*metal.PartitionCapacitySnapshot{
+				{Partition: "fra-equ01", Size: "n1-medium-x86", Free: 3, Taken: taken},
+				{Partition: "fra-equ01", Size: "c1-large-x86", Free: 1, Taken: taken},
+			},
+			want: []*LowCapacity{
+				{Partition: "fra-equ01", Size: "c1-large-x86", Free: 1, MinimumFree: 2, Taken: taken},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, lowCapacity(tt.snapshots, watermarks))
+		})
+	}
+}
+
+func Test_newlyLowCapacity(t *testing.T) {
+	var (
+		a = &LowCapacity{Partition: "fra-equ01", Size: "c1-large-x86", Free: 1}
+		b = &LowCapacity{Partition: "fra-equ02", Size: "c1-large-x86", Free: 1}
+	)
+
+	require.Equal(t, []*LowCapacity{b}, newlyLowCapacity([]*LowCapacity{a}, []*LowCapacity{a, b}))
+	require.Equal(t, []*LowCapacity{a, b}, newlyLowCapacity(nil, []*LowCapacity{a, b}))
+	require.Nil(t, newlyLowCapacity([]*LowCapacity{a, b}, []*LowCapacity{a}))
+}
+
+func Test_capacityEventTaskID(t *testing.T) {
+	var (
+		taken    = time.Date(2026, 1, 1, 10, 7, 0, 0, time.UTC)
+		low      = &LowCapacity{Partition: "fra-equ01", Size: "c1-large-x86", Taken: taken}
+		sameHour = &LowCapacity{Partition: "fra-equ01", Size: "c1-large-x86", Taken: taken.Add(30 * time.Minute)}
+		nextHour = &LowCapacity{Partition: "fra-equ01", Size: "c1-large-x86", Taken: taken.Add(time.Hour)}
+		other    = &LowCapacity{Partition: "fra-equ02", Size: "c1-large-x86", Taken: taken}
+	)
+
+	require.Equal(t, capacityEventTaskID(low, time.Hour), capacityEventTaskID(sameHour, time.Hour))
+	require.NotEqual(t, capacityEventTaskID(low, time.Hour), capacityEventTaskID(nextHour, time.Hour))
+	require.NotEqual(t, capacityEventTaskID(low, time.Hour), capacityEventTaskID(other, time.Hour))
+}
+
+func Test_ParseCapacityWatermarks(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []*CapacityWatermark
+		wantErr string
+	}{
+		{
+			name:  "size and partition watermarks",
+			specs: []string{"c1-large-x86=5", "c1-large-x86@fra-equ01=2"},
+			want: []*CapacityWatermark{
+				{Size: "c1-large-x86", MinimumFree: 5},
+				{Size: "c1-large-x86", Partition: "fra-equ01", MinimumFree: 2},
+			},
+		},
+		{
+			name:  "no watermarks",
+			specs: nil,
+			want:  nil,
+		},
+		{
+			name:    "missing minimum",
+			specs:   []string{"c1-large-x86"},
+			wantErr: `capacity watermark "c1-large-x86" must be in the form <size>[@<partition>]=<minimum-free>`,
+		},
+		{
+			name:    "missing size",
+			specs:   []string{"@fra-equ01=2"},
+			wantErr: `capacity watermark "@fra-equ01=2" must contain a size`,
+		},
+		{
+			name:    "minimum not positive",
+			specs:   []string{"c1-large-x86=0"},
+			wantErr: `minimum free machines of capacity watermark "c1-large-x86=0" must be a positive number`,
+		},
+		{
+			name:    "duplicate watermark",
+			specs:   []string{"c1-large-x86@fra-equ01=2", "c1-large-x86@fra-equ01=3"},
+			wantErr: `capacity watermark "c1-large-x86@fra-equ01" is given more than once`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCapacityWatermarks(tt.specs)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_PartitionCapacityEventHandleFn(t *testing.T) {
+	t.Parallel()
+
+	var (
+		unavailable atomic.Bool
+		received    = make(chan *CapacityEvent, 1)
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var event CapacityEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- &event
+	}))
+	defer ts.Close()
+
+	store := &Store{
+		log:      slog.Default(),
+		capacity: CapacityConfig{WebhookURL: ts.URL},
+	}
+
+	taken := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	payload, err := task.EncodePayload(&task.PartitionCapacityEventPayload{
+		Partition:   "partition-1",
+		Size:        "n1-medium-x86",
+		Free:        1,
+		MinimumFree: 3,
+		Time:        taken,
+	})
+	require.NoError(t, err)
+
+	event := asynq.NewTask(string(task.TypePartitionCapacityEvent), payload)
+
+	// a failed delivery returns an error, such that the task is retried
+	unavailable.Store(true)
+	require.EqualError(t, store.PartitionCapacityEventHandleFn(t.Context(), event), "webhook responded with statuscode:503")
+
+	unavailable.Store(false)
+	require.NoError(t, store.PartitionCapacityEventHandleFn(t.Context(), event))
+
+	require.Equal(t, &CapacityEvent{
+		Type:        "partition.capacity.low",
+		Partition:   "partition-1",
+		Size:        "n1-medium-x86",
+		Free:        1,
+		MinimumFree: 3,
+		Time:        taken,
+	}, <-received)
+}
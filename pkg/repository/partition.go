@@ -291,10 +291,11 @@ func (p *partitionRepository) Capacity(ctx context.Context, rq *adminv2.Partitio
 	// Last event error is a minor issue that describes an unexpected transition in the provisioning cycle.
 	// This happens quite often and you do not want to show these machines as unhealthy.
 	// see https://metal-stack.io/docs/troubleshooting#last-event-error
+	// Changed hardware needs to be acknowledged by an operator but does not render the machine unusable.
 	machinesWithIssues, err := issues.Find(&issues.Config{
 		Machines:        allMs,
 		EventContainers: ecs,
-		Omit:            []issues.Type{issues.TypeLastEventError},
+		Omit:            []issues.Type{issues.TypeLastEventError, issues.TypeHardwareChanged},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to calculate machine issues: %w", err)
@@ -58,7 +58,7 @@ func (r *projectRepository) validateDelete(ctx context.Context, req *projectEnti
 		return errorutil.FailedPrecondition("cannot remove project with existing size reservations of this project")
 	}
 
-	// TODO: ensure project tokens are revoked / cleaned up
+	// project tokens and invites are cleaned up asynchronously after the deletion
 
 	return nil
 }
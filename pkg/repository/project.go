@@ -7,6 +7,7 @@ import (
 
 	"github.com/metal-stack/api/go/errorutil"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
 	"github.com/metal-stack/metal-apiserver/pkg/tags"
 	"github.com/metal-stack/metal-lib/pkg/pointer"
@@ -147,7 +148,9 @@ func (r *projectRepository) delete(ctx context.Context, e *projectEntity) (*dele
 		return nil, errorutil.Convert(err)
 	}
 
-	return nil, nil
+	return r.s.revokeAccess(&task.AccessRevokePayload{
+		Project: e.Meta.Id,
+	})
 }
 
 func (r *projectRepository) find(ctx context.Context, query *apiv2.ProjectQuery) (*projectEntity, error) {
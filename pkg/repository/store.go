@@ -4,15 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"connectrpc.com/connect"
 	"github.com/metal-stack/api/go/errorutil"
 	"github.com/metal-stack/metal-apiserver/pkg/async/queue"
 	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/certs"
+	"github.com/metal-stack/metal-apiserver/pkg/console"
 	"github.com/metal-stack/metal-apiserver/pkg/db/generic"
 	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
 	"github.com/metal-stack/metal-apiserver/pkg/headscale"
+	"github.com/metal-stack/metal-apiserver/pkg/imageverify"
+	"github.com/metal-stack/metal-apiserver/pkg/invite"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
 	"github.com/metal-stack/metal-apiserver/pkg/request"
 	"github.com/metal-stack/metal-apiserver/pkg/token"
@@ -35,9 +39,17 @@ type (
 		queue           *queue.Queue
 		component       valkey.Client
 		auditing        auditing.Auditing
+		auditBackends   []auditing.Auditing
 		headscaleClient *headscale.Client
+		imageVerifier   *imageverify.Verifier
+		capacity        CapacityConfig
+		timeline        TimelineConfig
 		certs           certs.CertStore
 		tokens          token.TokenStore
+		projectInvites  invite.ProjectInviteStore
+		tenantInvites   invite.TenantInviteStore
+		consoleTickets  console.TicketStore
+		consoleBackend  console.Backend
 		issuer          string
 		providerTenant  string
 	}
@@ -51,8 +63,14 @@ type (
 		Queue                 *queue.Queue
 		Component             valkey.Client
 		Auditing              auditing.Auditing
+		AuditBackends         []auditing.Auditing
 		HeadscaleClient       *headscale.Client
+		ImageVerifier         *imageverify.Verifier
 		TokenConfig           TokenConfig
+		InviteConfig          InviteConfig
+		ConsoleConfig         ConsoleConfig
+		CapacityConfig        CapacityConfig
+		TimelineConfig        TimelineConfig
 	}
 
 	TokenConfig struct {
@@ -65,6 +83,33 @@ type (
 		Issuer string
 	}
 
+	InviteConfig struct {
+		ProjectInviteStore invite.ProjectInviteStore
+		TenantInviteStore  invite.TenantInviteStore
+	}
+
+	ConsoleConfig struct {
+		TicketStore console.TicketStore
+		// Backend delivers issued tickets to the serial console gateway, tickets are only stored if nil
+		Backend console.Backend
+	}
+
+	CapacityConfig struct {
+		// SnapshotRetention is the duration after which partition capacity snapshots are deleted, they are kept forever if zero
+		SnapshotRetention time.Duration
+		// SnapshotInterval is the interval in which partition capacity snapshots are taken, a capacity event is sent at most once per interval
+		SnapshotInterval time.Duration
+		// WebhookURL receives an event when the free machines of a size in a partition drop below their watermark, no events are sent if empty
+		WebhookURL string
+		// Watermarks define the free machines per size below which the capacity is low
+		Watermarks []*CapacityWatermark
+	}
+
+	TimelineConfig struct {
+		// Retention is the duration after which machine timeline entries are deleted, they are kept forever if zero
+		Retention time.Duration
+	}
+
 	store[R Repo, E Entity, M Message, C CreateMessage, U UpdateMessage, Q Query] struct {
 		typed R
 		repository[E, M, C, U, Q]
@@ -72,6 +117,12 @@ type (
 )
 
 func New(c Config) *Store {
+	imageVerifier := c.ImageVerifier
+	if imageVerifier == nil {
+		// checksums are not required by default, they are only enforced if configured explicitly
+		imageVerifier = imageverify.New(imageverify.Config{})
+	}
+
 	return &Store{
 		log:             c.Log,
 		tc:              c.TenantApiserverClient,
@@ -81,9 +132,17 @@ func New(c Config) *Store {
 		queue:           c.Queue,
 		component:       c.Component,
 		auditing:        c.Auditing,
+		auditBackends:   c.AuditBackends,
 		headscaleClient: c.HeadscaleClient,
+		imageVerifier:   imageVerifier,
+		capacity:        c.CapacityConfig,
+		timeline:        c.TimelineConfig,
 		certs:           c.TokenConfig.CertStore,
 		tokens:          c.TokenConfig.TokenStore,
+		projectInvites:  c.InviteConfig.ProjectInviteStore,
+		tenantInvites:   c.InviteConfig.TenantInviteStore,
+		consoleTickets:  c.ConsoleConfig.TicketStore,
+		consoleBackend:  c.ConsoleConfig.Backend,
 		issuer:          c.TokenConfig.Issuer,
 		providerTenant:  c.TokenConfig.ProviderTenant,
 	}
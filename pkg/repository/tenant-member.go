@@ -6,6 +6,7 @@ import (
 
 	"github.com/metal-stack/api/go/errorutil"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
 	tenantv1 "github.com/metal-stack/tenant-api/go/api/v1"
 )
@@ -81,6 +82,15 @@ func (t *tenantMemberRepository) delete(ctx context.Context, e *tenantMemberEnti
 		return nil, errorutil.Convert(err)
 	}
 
+	// members have no meta to carry the deletion task id, the revocation is enqueued nevertheless
+	_, err = t.s.revokeAccess(&task.AccessRevokePayload{
+		Tenant: e.TenantId,
+		User:   e.MemberId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
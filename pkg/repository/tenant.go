@@ -9,6 +9,7 @@ import (
 	"github.com/metal-stack/api/go/errorutil"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
 	"github.com/metal-stack/api/go/tag"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
 	"github.com/metal-stack/metal-apiserver/pkg/tags"
 	"github.com/metal-stack/metal-apiserver/pkg/token"
@@ -125,7 +126,9 @@ func (t *tenantRepository) delete(ctx context.Context, e *tenantEntity) (*delete
 		return nil, errorutil.Convert(err)
 	}
 
-	return nil, nil
+	return t.s.revokeAccess(&task.AccessRevokePayload{
+		Tenant: e.Meta.Id,
+	})
 }
 
 func (t *tenantRepository) find(ctx context.Context, query *apiv2.TenantQuery) (*tenantEntity, error) {
@@ -2,12 +2,15 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"slices"
 	"time"
 
+	"github.com/hibiken/asynq"
 	"github.com/metal-stack/api/go/errorutil"
 	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/repository/api"
 	"github.com/metal-stack/metal-apiserver/pkg/request"
 	"github.com/metal-stack/metal-apiserver/pkg/tags"
@@ -604,3 +607,136 @@ func sortUniq(s []string) []string {
 	slices.Sort(s)
 	return lo.Uniq(s)
 }
+
+// AccessRevokeHandleFn removes the roles and permissions of a removed project or tenant membership from the stored tokens.
+// Tokens which do not grant any access anymore after this removal are revoked.
+// If no user is given, the project or tenant was deleted and the open invites are deleted as well.
+func (r *Store) AccessRevokeHandleFn(ctx context.Context, t *asynq.Task) error {
+	payload, err := task.DecodePayload[*task.AccessRevokePayload](t.Payload())
+	if err != nil {
+		return err
+	}
+
+	r.log.Info("revoke access", "project", payload.Project, "tenant", payload.Tenant, "user", payload.User)
+
+	var tokens []*apiv2.Token
+
+	if payload.User != "" {
+		tokens, err = r.tokens.List(ctx, payload.User)
+	} else {
+		tokens, err = r.tokens.AdminList(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to list tokens: %w", err)
+	}
+
+	for _, tok := range tokens {
+		if !removeTokenAccess(tok, payload.Project, payload.Tenant) {
+			continue
+		}
+
+		if tokenGrantsNoAccess(tok) {
+			err := r.tokens.Revoke(ctx, tok.User, tok.Uuid)
+			if err != nil && !errorutil.IsNotFound(err) {
+				return fmt.Errorf("unable to revoke token %q: %w", tok.Uuid, err)
+			}
+
+			r.log.Info("revoked token without remaining access", "user", tok.User, "token", tok.Uuid)
+
+			continue
+		}
+
+		if err := r.tokens.Set(ctx, tok); err != nil {
+			return fmt.Errorf("unable to update token %q: %w", tok.Uuid, err)
+		}
+
+		r.log.Info("removed access from token", "user", tok.User, "token", tok.Uuid)
+	}
+
+	if payload.User != "" {
+		return nil
+	}
+
+	if payload.Project != "" && r.projectInvites != nil {
+		invites, err := r.projectInvites.ListInvites(ctx, payload.Project)
+		if err != nil {
+			return fmt.Errorf("unable to list project invites: %w", err)
+		}
+
+		for _, inv := range invites {
+			if err := r.projectInvites.DeleteInvite(ctx, inv); err != nil {
+				return fmt.Errorf("unable to delete project invite: %w", err)
+			}
+		}
+	}
+
+	if payload.Tenant != "" && r.tenantInvites != nil {
+		invites, err := r.tenantInvites.ListInvites(ctx, payload.Tenant)
+		if err != nil {
+			return fmt.Errorf("unable to list tenant invites: %w", err)
+		}
+
+		for _, inv := range invites {
+			if err := r.tenantInvites.DeleteInvite(ctx, inv); err != nil {
+				return fmt.Errorf("unable to delete tenant invite: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// revokeAccess enqueues a task which removes the access to the given project or tenant from tokens and invites.
+func (s *Store) revokeAccess(payload *task.AccessRevokePayload) (*deleteInfo, error) {
+	info, err := s.task.NewTask(payload)
+	if err != nil {
+		return nil, errorutil.Internal("unable to enqueue access revocation: %w", err)
+	}
+
+	s.log.Info("access revoke enqueued", "info", info)
+
+	return &deleteInfo{
+		taskID: &info.ID,
+	}, nil
+}
+
+// removeTokenAccess removes all roles and permissions for the given project or tenant from the token.
+// It returns true if the token was modified.
+func removeTokenAccess(tok *apiv2.Token, project, tenant string) bool {
+	changed := false
+
+	for _, subject := range []string{project, tenant} {
+		if subject == "" {
+			continue
+		}
+
+		if _, ok := tok.ProjectRoles[subject]; ok {
+			delete(tok.ProjectRoles, subject)
+			changed = true
+		}
+		if _, ok := tok.TenantRoles[subject]; ok {
+			delete(tok.TenantRoles, subject)
+			changed = true
+		}
+
+		before := len(tok.Permissions)
+		tok.Permissions = slices.DeleteFunc(tok.Permissions, func(p *apiv2.MethodPermission) bool {
+			return p.Subject == subject
+		})
+		if len(tok.Permissions) != before {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// tokenGrantsNoAccess returns true if the token does not carry any roles or permissions.
+func tokenGrantsNoAccess(tok *apiv2.Token) bool {
+	return len(tok.ProjectRoles) == 0 &&
+		len(tok.TenantRoles) == 0 &&
+		len(tok.MachineRoles) == 0 &&
+		len(tok.Permissions) == 0 &&
+		tok.AdminRole == nil &&
+		tok.InfraRole == nil
+}
@@ -425,3 +425,105 @@ func Test_flattenPermissions(t *testing.T) {
 		})
 	}
 }
+
+func Test_removeTokenAccess(t *testing.T) {
+	tests := []struct {
+		name         string
+		tok          *apiv2.Token
+		project      string
+		tenant       string
+		want         *apiv2.Token
+		wantChanged  bool
+		wantNoAccess bool
+	}{
+		{
+			name: "user token without roles is untouched",
+			tok: &apiv2.Token{
+				Uuid:      "1",
+				TokenType: apiv2.TokenType_TOKEN_TYPE_USER,
+			},
+			project: "p1",
+			want: &apiv2.Token{
+				Uuid:      "1",
+				TokenType: apiv2.TokenType_TOKEN_TYPE_USER,
+			},
+			wantChanged:  false,
+			wantNoAccess: true,
+		},
+		{
+			name: "project role and permissions are removed, other project remains",
+			tok: &apiv2.Token{
+				Uuid: "1",
+				ProjectRoles: map[string]apiv2.ProjectRole{
+					"p1": apiv2.ProjectRole_PROJECT_ROLE_OWNER,
+					"p2": apiv2.ProjectRole_PROJECT_ROLE_VIEWER,
+				},
+				Permissions: []*apiv2.MethodPermission{
+					{Subject: "p1", Methods: []string{apiv2connect.IPServiceCreateProcedure}},
+					{Subject: "p2", Methods: []string{apiv2connect.IPServiceGetProcedure}},
+				},
+			},
+			project: "p1",
+			want: &apiv2.Token{
+				Uuid: "1",
+				ProjectRoles: map[string]apiv2.ProjectRole{
+					"p2": apiv2.ProjectRole_PROJECT_ROLE_VIEWER,
+				},
+				Permissions: []*apiv2.MethodPermission{
+					{Subject: "p2", Methods: []string{apiv2connect.IPServiceGetProcedure}},
+				},
+			},
+			wantChanged:  true,
+			wantNoAccess: false,
+		},
+		{
+			name: "token is left without access after tenant removal",
+			tok: &apiv2.Token{
+				Uuid: "1",
+				TenantRoles: map[string]apiv2.TenantRole{
+					"tenant-a": apiv2.TenantRole_TENANT_ROLE_EDITOR,
+				},
+				Permissions: []*apiv2.MethodPermission{
+					{Subject: "tenant-a", Methods: []string{apiv2connect.TenantServiceGetProcedure}},
+				},
+			},
+			tenant: "tenant-a",
+			want: &apiv2.Token{
+				Uuid:        "1",
+				TenantRoles: map[string]apiv2.TenantRole{},
+				Permissions: []*apiv2.MethodPermission{},
+			},
+			wantChanged:  true,
+			wantNoAccess: true,
+		},
+		{
+			name: "admin token keeps access",
+			tok: &apiv2.Token{
+				Uuid:      "1",
+				AdminRole: apiv2.AdminRole_ADMIN_ROLE_VIEWER.Enum(),
+				ProjectRoles: map[string]apiv2.ProjectRole{
+					"p1": apiv2.ProjectRole_PROJECT_ROLE_OWNER,
+				},
+			},
+			project: "p1",
+			want: &apiv2.Token{
+				Uuid:         "1",
+				AdminRole:    apiv2.AdminRole_ADMIN_ROLE_VIEWER.Enum(),
+				ProjectRoles: map[string]apiv2.ProjectRole{},
+			},
+			wantChanged:  true,
+			wantNoAccess: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := removeTokenAccess(tt.tok, tt.project, tt.tenant)
+			assert.Equal(t, tt.wantChanged, changed)
+			assert.Equal(t, tt.wantNoAccess, tokenGrantsNoAccess(tt.tok))
+
+			if diff := cmp.Diff(tt.want, tt.tok, protocmp.Transform()); diff != "" {
+				t.Errorf("diff = %s", diff)
+			}
+		})
+	}
+}
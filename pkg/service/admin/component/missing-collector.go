@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/api/go/enum"
+	"github.com/metal-stack/metal-apiserver/pkg/repository"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var missingComponentDesc = prometheus.NewDesc(
+	"metal_apiserver_component_missing",
+	"components which did not report within two of their ping intervals, the value is the unix timestamp of the last report",
+	[]string{"type", "identifier"},
+	nil,
+)
+
+type missingCollector struct {
+	log  *slog.Logger
+	repo *repository.Store
+}
+
+// NewMissingCollector returns a prometheus collector which reports missing components on every scrape,
+// alerts can be defined on top of it.
+func NewMissingCollector(log *slog.Logger, repo *repository.Store) prometheus.Collector {
+	return &missingCollector{
+		log:  log,
+		repo: repo,
+	}
+}
+
+func (m *missingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- missingComponentDesc
+}
+
+func (m *missingCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	missing, err := m.repo.Component().AdditionalMethods().Missing(ctx)
+	if err != nil {
+		m.log.Error("unable to evaluate missing components", "error", err)
+		ch <- prometheus.NewInvalidMetric(missingComponentDesc, err)
+		return
+	}
+
+	for _, c := range missing {
+		typeAsString, err := enum.GetStringValue(c.Type)
+		if err != nil {
+			m.log.Error("unable to convert component type", "type", c.Type, "error", err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(missingComponentDesc, prometheus.GaugeValue, float64(c.ReportedAt.AsTime().Unix()), *typeAsString, c.Identifier)
+	}
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-apiserver/pkg/repository"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var lowCapacityDesc = prometheus.NewDesc(
+	"metal_apiserver_partition_capacity_low",
+	"sizes whose free machines in a partition are below their watermark, the value is the number of free machines",
+	[]string{"partition", "size"},
+	nil,
+)
+
+type lowCapacityCollector struct {
+	log  *slog.Logger
+	repo *repository.Store
+}
+
+// NewLowCapacityCollector returns a prometheus collector which reports sizes with low capacity on every scrape,
+// alerts can be defined on top of it.
+func NewLowCapacityCollector(log *slog.Logger, repo *repository.Store) prometheus.Collector {
+	return &lowCapacityCollector{
+		log:  log,
+		repo: repo,
+	}
+}
+
+func (l *lowCapacityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lowCapacityDesc
+}
+
+func (l *lowCapacityCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	low, err := l.repo.Partition().AdditionalMethods().LowCapacity(ctx)
+	if err != nil {
+		l.log.Error("unable to evaluate low capacity", "error", err)
+		ch <- prometheus.NewInvalidMetric(lowCapacityDesc, err)
+		return
+	}
+
+	for _, c := range low {
+		ch <- prometheus.MustNewConstMetric(lowCapacityDesc, prometheus.GaugeValue, float64(c.Free), c.Partition, c.Size)
+	}
+}
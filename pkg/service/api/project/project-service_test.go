@@ -776,11 +776,17 @@ func Test_projectServiceServer_Delete(t *testing.T) {
 				tt.want, got,
 				protocmp.Transform(),
 				protocmp.IgnoreFields(
-					&apiv2.Meta{}, "created_at", "updated_at",
+					&apiv2.Meta{}, "created_at", "updated_at", "deletion_task_id",
 				),
 			); diff != "" {
 				t.Errorf("%v, want %v diff: %s", got, tt.want, diff)
 			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			assert.NotNil(t, got.Project.Meta.DeletionTaskId)
 		})
 	}
 }
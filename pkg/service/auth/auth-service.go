@@ -3,14 +3,18 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -45,11 +49,12 @@ type Config struct {
 }
 
 type providerUser struct {
-	Login     string `json:"login"`
-	Name      string `json:"name"`
-	Email     string `json:"email"`
-	AvatarUrl string `json:"avatar_url"`
-	Provider  string `json:"provider"`
+	Login     string         `json:"login"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	AvatarUrl string         `json:"avatar_url"`
+	Provider  string         `json:"provider"`
+	Claims    map[string]any `json:"-"`
 }
 
 type providerBackend interface {
@@ -58,8 +63,16 @@ type providerBackend interface {
 	EndSessionRedirectURL() string
 }
 
+// credentialsBackend is implemented by providers which authenticate users with username and password
+// instead of redirecting them to an identity provider.
+type credentialsBackend interface {
+	providerBackend
+	Authenticate(ctx context.Context, username, password string) (*providerUser, error)
+}
+
 type auth struct {
 	providerBackends map[string]providerBackend
+	providerSettings map[string]*providerSettings
 	auditBackends    []auditing.Auditing
 	log              *slog.Logger
 	frontEndUrl      *url.URL
@@ -77,6 +90,7 @@ func New(c Config, options ...authOption) (*auth, error) {
 		log:              c.Log,
 		auditBackends:    c.AuditBackends,
 		providerBackends: map[string]providerBackend{},
+		providerSettings: map[string]*providerSettings{},
 		frontEndUrl:      c.FrontEndUrl,
 		callbackUrl:      c.CallbackUrl,
 		redirectUrls:     c.RedirectUrls,
@@ -185,7 +199,55 @@ func parseState(in string) (*state, error) {
 	return o, nil
 }
 
+func credentialsStateKey(providerName string) string {
+	return providerName + "-state"
+}
+
+// verifyCredentialsState compares the posted state with the state which was stored in the session
+// of the browser when the login form was requested. The stored state is consumed, it can only be used once.
+func verifyCredentialsState(res http.ResponseWriter, req *http.Request, providerName, posted string) error {
+	stored, err := gothic.GetFromSession(credentialsStateKey(providerName), req)
+	if err != nil {
+		return fmt.Errorf("no state found in session: %w", err)
+	}
+
+	err = gothic.StoreInSession(credentialsStateKey(providerName), "", req, res)
+	if err != nil {
+		return fmt.Errorf("unable to remove state from session: %w", err)
+	}
+
+	if stored == "" || subtle.ConstantTimeCompare([]byte(stored), []byte(posted)) != 1 {
+		return errors.New("state mismatch")
+	}
+
+	return nil
+}
+
+// providerFromRequest returns the provider backend which is addressed by the {provider} path parameter.
+// The provider name is also set as query parameter because gothic would otherwise prefer a provider
+// given by the client in the query.
+func (a *auth) providerFromRequest(req *http.Request) (providerBackend, bool) {
+	providerName := mux.Vars(req)[providerKey]
+
+	provider, ok := a.providerBackends[providerName]
+	if !ok {
+		return nil, false
+	}
+
+	q := req.URL.Query()
+	q.Set(providerKey, providerName)
+	req.URL.RawQuery = q.Encode()
+
+	return provider, true
+}
+
 func (a *auth) Login(res http.ResponseWriter, req *http.Request) {
+	provider, ok := a.providerFromRequest(req)
+	if !ok {
+		http.Error(res, "unknown provider", http.StatusNotFound)
+		return
+	}
+
 	// CheckLoggedIn
 	// try to get the user without re-authenticating
 
@@ -194,7 +256,19 @@ func (a *auth) Login(res http.ResponseWriter, req *http.Request) {
 		http.Error(res, fmt.Sprintf("unable to set state: %v", err), http.StatusInternalServerError)
 		return
 	}
-	a.log.Info("login", "state", state)
+	a.log.Info("login", "provider", provider.Name(), "state", state)
+
+	if _, ok := provider.(credentialsBackend); ok {
+		// the posted state is only accepted from the browser which requested the login form
+		err = gothic.StoreInSession(credentialsStateKey(provider.Name()), state, req, res)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("unable to store state: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.loginForm(res, provider.Name(), state)
+		return
+	}
 
 	q := req.URL.Query()
 	q.Add("state", state)
@@ -211,18 +285,14 @@ func (a *auth) Login(res http.ResponseWriter, req *http.Request) {
 }
 
 func (a *auth) Logout(res http.ResponseWriter, req *http.Request) {
+	provider, ok := a.providerFromRequest(req)
+
 	err := gothic.Logout(res, req)
 	if err != nil {
 		http.Error(res, "logout failed", http.StatusInternalServerError)
 		return
 	}
 
-	providerName, err := gothic.GetProviderName(req)
-	if err != nil {
-		a.log.Error("cannot obtain provider name", "err", err)
-	}
-	provider, ok := a.providerBackends[providerName]
-
 	if ok && provider.EndSessionRedirectURL() != "" {
 		http.Redirect(res, req, provider.EndSessionRedirectURL(), http.StatusSeeOther)
 		return
@@ -237,32 +307,67 @@ func (a *auth) Logout(res http.ResponseWriter, req *http.Request) {
 func (a *auth) Callback(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
-	state, err := parseState(req.URL.Query().Get("state"))
-	if err != nil {
-		a.log.Error("unable to parse state", "err", err)
-		http.Error(res, "unable to parse state", http.StatusInternalServerError)
-		return
-	}
-
-	providerName, err := gothic.GetProviderName(req)
-	if err != nil {
-		a.log.Error("cannot obtain provider name", "err", err)
-	}
-	provider, ok := a.providerBackends[providerName]
+	provider, ok := a.providerFromRequest(req)
 	if !ok {
-		a.log.Error("callback no provider backend found for", "provider", providerName)
+		a.log.Error("callback no provider backend found for", "provider", mux.Vars(req)[providerKey])
 		http.Error(res, "no provider backend", http.StatusBadRequest)
 		return
 	}
-	user, err := gothic.CompleteUserAuth(res, req)
-	if err != nil {
-		a.log.Error("failed to complete user auth", "err", err)
-		return
-	}
-	u, err := provider.User(ctx, user)
-	if err != nil {
-		http.Error(res, fmt.Sprintf("unable to extract user: %v", err), http.StatusUnauthorized)
-		return
+
+	var (
+		u     *providerUser
+		state *state
+		err   error
+	)
+
+	if credentials, ok := provider.(credentialsBackend); ok {
+		if req.Method != http.MethodPost {
+			http.Error(res, "credentials must be posted", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err = verifyCredentialsState(res, req, provider.Name(), req.PostFormValue("state"))
+		if err != nil {
+			a.log.Error("unable to verify state", "err", err)
+			http.Error(res, "unable to verify state", http.StatusBadRequest)
+			return
+		}
+
+		state, err = parseState(req.PostFormValue("state"))
+		if err != nil {
+			a.log.Error("unable to parse state", "err", err)
+			http.Error(res, "unable to parse state", http.StatusBadRequest)
+			return
+		}
+
+		u, err = credentials.Authenticate(ctx, req.PostFormValue("username"), req.PostFormValue("password"))
+		if errors.Is(err, errInvalidCredentials) {
+			http.Error(res, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			a.log.Error("unable to authenticate user", "provider", provider.Name(), "error", err)
+			http.Error(res, "unable to authenticate user", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		state, err = parseState(req.URL.Query().Get("state"))
+		if err != nil {
+			a.log.Error("unable to parse state", "err", err)
+			http.Error(res, "unable to parse state", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := gothic.CompleteUserAuth(res, req)
+		if err != nil {
+			a.log.Error("failed to complete user auth", "err", err)
+			return
+		}
+		u, err = provider.User(ctx, user)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("unable to extract user: %v", err), http.StatusUnauthorized)
+			return
+		}
 	}
 
 	// Ensure tenant and token
@@ -273,6 +378,12 @@ func (a *auth) Callback(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	err = a.applyTenantMappings(ctx, provider.Name(), u)
+	if err != nil {
+		http.Error(res, fmt.Sprintf("unable to apply tenant mappings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	// Create Token
 
 	pat, err := a.repo.UnscopedProject().AdditionalMethods().GetProjectsAndTenants(ctx, u.Login)
@@ -342,7 +453,7 @@ func (a *auth) Callback(res http.ResponseWriter, req *http.Request) {
 
 	a.log.Debug("redirecting back", "url", redirectURL.String())
 
-	if err := a.isRedirectURLAllowed(redirectURL); err != nil {
+	if err := a.isRedirectURLAllowed(provider.Name(), redirectURL); err != nil {
 		a.log.Error("redirect url is not allowed", "error", err)
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
@@ -351,11 +462,17 @@ func (a *auth) Callback(res http.ResponseWriter, req *http.Request) {
 	http.Redirect(res, req, redirectURL.String(), http.StatusSeeOther)
 }
 
-func (a *auth) isRedirectURLAllowed(url *url.URL) error {
+func (a *auth) isRedirectURLAllowed(providerName string, url *url.URL) error {
 	if url == nil {
 		return fmt.Errorf("redirect url is nil")
 	}
-	for _, u := range a.redirectUrls {
+
+	allowed := a.redirectUrls
+	if settings, ok := a.providerSettings[providerName]; ok {
+		allowed = append(slices.Clone(allowed), settings.redirectUrls...)
+	}
+
+	for _, u := range allowed {
 		if u.Hostname() != url.Hostname() {
 			continue
 		}
@@ -364,7 +481,74 @@ func (a *auth) isRedirectURLAllowed(url *url.URL) error {
 		}
 		return nil
 	}
-	return fmt.Errorf("given url %q is not in the configured list of allowed redirect urls %v", url.String(), a.redirectUrls)
+	return fmt.Errorf("given url %q is not in the configured list of allowed redirect urls %v", url.String(), allowed)
+}
+
+var loginFormTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Login with {{ .Provider }}</title></head>
+<body>
+<form method="post" action="{{ .Action }}">
+<input type="hidden" name="state" value="{{ .State }}">
+<label>Username <input type="text" name="username" autocomplete="username" required autofocus></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+<button type="submit">Login</button>
+</form>
+</body>
+</html>
+`))
+
+func (a *auth) loginForm(res http.ResponseWriter, providerName, state string) {
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	err := loginFormTemplate.Execute(res, map[string]string{
+		"Provider": providerName,
+		"Action":   a.ProviderCallbackURL(providerName),
+		"State":    state,
+	})
+	if err != nil {
+		a.log.Error("unable to render login form", "error", err)
+	}
+}
+
+// applyTenantMappings makes the user a member of all tenants which are mapped by the provider.
+// Existing memberships are not modified.
+func (a *auth) applyTenantMappings(ctx context.Context, providerName string, u *providerUser) error {
+	settings, ok := a.providerSettings[providerName]
+	if !ok {
+		return nil
+	}
+
+	for _, m := range settings.tenantMappings {
+		if !m.matches(u.Claims) {
+			continue
+		}
+
+		role, err := m.tenantRole()
+		if err != nil {
+			return err
+		}
+
+		_, err = a.repo.Tenant().AdditionalMethods().Member(m.Tenant).Get(ctx, u.Login)
+		if err == nil {
+			continue
+		}
+		if !errorutil.IsNotFound(err) {
+			return fmt.Errorf("unable to get membership of %s in tenant %s: %w", u.Login, m.Tenant, err)
+		}
+
+		_, err = a.repo.Tenant().AdditionalMethods().Member(m.Tenant).Create(ctx, &api.TenantMemberCreateRequest{
+			Role:     role,
+			MemberID: u.Login,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to add %s to tenant %s: %w", u.Login, m.Tenant, err)
+		}
+
+		a.log.Info("added user to tenant by mapping", "provider", providerName, "user", u.Login, "tenant", m.Tenant, "role", role.String())
+	}
+
+	return nil
 }
 
 func (a *auth) ensureTenant(ctx context.Context, u *providerUser) error {
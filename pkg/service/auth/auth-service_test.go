@@ -1,18 +1,23 @@
 package auth
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_auth_isRedirectURLAllowed(t *testing.T) {
 	tests := []struct {
-		name         string
-		redirectUrls []string
-		url          string
-		wantErr      bool
+		name                 string
+		redirectUrls         []string
+		providerRedirectUrls []string
+		url                  string
+		wantErr              bool
 	}{
 		{
 			name:         "url is allowed",
@@ -38,6 +43,20 @@ func Test_auth_isRedirectURLAllowed(t *testing.T) {
 			url:          "http://localhost:8080/login?token=asdf",
 			wantErr:      true,
 		},
+		{
+			name:                 "url is allowed by provider",
+			redirectUrls:         []string{"https://metal-stack.io"},
+			providerRedirectUrls: []string{"https://partner.example.com"},
+			url:                  "https://partner.example.com/login?token=asdf",
+			wantErr:              false,
+		},
+		{
+			name:                 "global url is still allowed for provider",
+			redirectUrls:         []string{"https://metal-stack.io"},
+			providerRedirectUrls: []string{"https://partner.example.com"},
+			url:                  "https://metal-stack.io/login?token=asdf",
+			wantErr:              false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,11 +72,17 @@ func Test_auth_isRedirectURLAllowed(t *testing.T) {
 				require.NoError(t, err)
 				redirectUrls = append(redirectUrls, parsed)
 			}
+			settings, err := newProviderSettings(tt.providerRedirectUrls, nil)
+			require.NoError(t, err)
+
 			a := auth{
 				redirectUrls: redirectUrls,
+				providerSettings: map[string]*providerSettings{
+					"partner": settings,
+				},
 			}
 
-			gotErr := a.isRedirectURLAllowed(requestedUrl)
+			gotErr := a.isRedirectURLAllowed("partner", requestedUrl)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("isRedirectURLAllowed() failed: %v", gotErr)
@@ -70,3 +95,45 @@ func Test_auth_isRedirectURLAllowed(t *testing.T) {
 		})
 	}
 }
+
+func Test_verifyCredentialsState(t *testing.T) {
+	gothic.Store = sessions.NewCookieStore([]byte("test-session-secret"))
+
+	login := func(t *testing.T, state string) []*http.Cookie {
+		res := httptest.NewRecorder()
+		require.NoError(t, gothic.StoreInSession(credentialsStateKey("ldap"), state, httptest.NewRequest(http.MethodGet, "/auth/ldap", nil), res))
+		return res.Result().Cookies()
+	}
+
+	callback := func(cookies []*http.Cookie, posted string) ([]*http.Cookie, error) {
+		req := httptest.NewRequest(http.MethodPost, "/auth/ldap/callback", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		res := httptest.NewRecorder()
+		err := verifyCredentialsState(res, req, "ldap", posted)
+		return res.Result().Cookies(), err
+	}
+
+	t.Run("state of the session is accepted once", func(t *testing.T) {
+		cookies := login(t, "state-a")
+
+		consumed, err := callback(cookies, "state-a")
+		require.NoError(t, err)
+
+		_, err = callback(consumed, "state-a")
+		require.EqualError(t, err, "state mismatch")
+	})
+
+	t.Run("state of another session is rejected", func(t *testing.T) {
+		cookies := login(t, "state-a")
+
+		_, err := callback(cookies, "state-b")
+		require.EqualError(t, err, "state mismatch")
+	})
+
+	t.Run("state without session is rejected", func(t *testing.T) {
+		_, err := callback(nil, "state-a")
+		require.Error(t, err)
+	})
+}
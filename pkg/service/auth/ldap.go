@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/markbates/goth"
+)
+
+const (
+	ldapDefaultUserFilter     = "(uid=%s)"
+	ldapDefaultUniqueUserKey  = "uid"
+	ldapDefaultNameAttribute  = "cn"
+	ldapDefaultEmailAttribute = "mail"
+	ldapTimeout               = 10 * time.Second
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+type LDAPProviderConfig struct {
+	// Name is used in the login and callback urls and as suffix of the user logins
+	Name string `yaml:"name"`
+	// URL of the ldap server, e.g. ldaps://ldap.example.com:636
+	URL string `yaml:"url"`
+	// BindDN and BindPassword are used to search for the user, if empty an anonymous search is done
+	BindDN       string `yaml:"bind_dn"`
+	BindPassword string `yaml:"bind_password"`
+	BaseDN       string `yaml:"base_dn"`
+	// UserFilter is used to search for the user, %s is replaced with the escaped username, defaults to (uid=%s)
+	UserFilter string `yaml:"user_filter"`
+	// UniqueUserKey is the attribute which identifies the user, defaults to uid
+	UniqueUserKey  string `yaml:"unique_user_key"`
+	NameAttribute  string `yaml:"name_attribute"`
+	EmailAttribute string `yaml:"email_attribute"`
+	TLSSkipVerify  bool   `yaml:"tls_skip_verify"`
+	// RedirectURLs are allowed as redirect after login in addition to the globally configured redirect urls
+	RedirectURLs   []string        `yaml:"redirect_urls"`
+	TenantMappings []TenantMapping `yaml:"tenant_mappings"`
+}
+
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+type ldapProvider struct {
+	log  *slog.Logger
+	c    LDAPProviderConfig
+	dial func() (ldapConn, error)
+}
+
+// LDAPProvider authenticates users with username and password against an ldap server.
+// In contrast to the oidc providers, the login form is served by the api-server itself.
+func LDAPProvider(c LDAPProviderConfig) authOption {
+	return func(a *auth) error {
+		if c.Name == "" {
+			return fmt.Errorf("ldap provider name is not configured")
+		}
+		if c.URL == "" || c.BaseDN == "" {
+			return fmt.Errorf("ldap url or base dn is not configured")
+		}
+
+		if c.UserFilter == "" {
+			c.UserFilter = ldapDefaultUserFilter
+		}
+		if c.UniqueUserKey == "" {
+			c.UniqueUserKey = ldapDefaultUniqueUserKey
+		}
+		if c.NameAttribute == "" {
+			c.NameAttribute = ldapDefaultNameAttribute
+		}
+		if c.EmailAttribute == "" {
+			c.EmailAttribute = ldapDefaultEmailAttribute
+		}
+
+		settings, err := newProviderSettings(c.RedirectURLs, c.TenantMappings)
+		if err != nil {
+			return err
+		}
+
+		p := &ldapProvider{
+			log: a.log,
+			c:   c,
+			dial: func() (ldapConn, error) {
+				return ldap.DialURL(c.URL, ldap.DialWithTLSConfig(&tls.Config{
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: c.TLSSkipVerify,
+				}))
+			},
+		}
+
+		a.AddProviderBackend(p)
+		a.providerSettings[p.Name()] = settings
+
+		a.log.Info("configured ldap provider", "provider", p.Name())
+
+		return nil
+	}
+}
+
+func (l *ldapProvider) Name() string {
+	return l.c.Name
+}
+
+func (l *ldapProvider) EndSessionRedirectURL() string {
+	return ""
+}
+
+func (l *ldapProvider) User(_ context.Context, _ goth.User) (*providerUser, error) {
+	return nil, fmt.Errorf("ldap provider %q does not support oauth logins", l.Name())
+}
+
+func (l *ldapProvider) Authenticate(_ context.Context, username, password string) (*providerUser, error) {
+	if username == "" || password == "" {
+		// an empty password would result in an unauthenticated bind which succeeds on most servers
+		return nil, errInvalidCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to ldap server: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if l.c.BindDN != "" {
+		err = conn.Bind(l.c.BindDN, l.c.BindPassword)
+		if err != nil {
+			return nil, fmt.Errorf("unable to bind with service account: %w", err)
+		}
+	}
+
+	attributes := append([]string{l.c.UniqueUserKey, l.c.NameAttribute, l.c.EmailAttribute}, l.mappingClaims()...)
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.c.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		int(ldapTimeout.Seconds()),
+		false,
+		fmt.Sprintf(l.c.UserFilter, ldap.EscapeFilter(username)),
+		attributes,
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("unable to search for user: %w", err)
+	}
+
+	if len(result.Entries) != 1 {
+		l.log.Debug("ldap user search did not return exactly one entry", "provider", l.Name(), "username", username, "entries", len(result.Entries))
+		return nil, errInvalidCredentials
+	}
+
+	entry := result.Entries[0]
+
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errInvalidCredentials
+		}
+		return nil, fmt.Errorf("unable to bind as user: %w", err)
+	}
+
+	login := entry.GetAttributeValue(l.c.UniqueUserKey)
+	if login == "" {
+		return nil, fmt.Errorf("ldap entry does not contain %q attribute", l.c.UniqueUserKey)
+	}
+
+	claims := map[string]any{}
+	for _, attr := range entry.Attributes {
+		claims[attr.Name] = attr.Values
+	}
+
+	return &providerUser{
+		Login:    login + "@" + l.Name(),
+		Name:     entry.GetAttributeValue(l.c.NameAttribute),
+		Email:    entry.GetAttributeValue(l.c.EmailAttribute),
+		Provider: l.Name(),
+		Claims:   claims,
+	}, nil
+}
+
+func (l *ldapProvider) mappingClaims() []string {
+	var claims []string
+	for _, m := range l.c.TenantMappings {
+		if m.Claim != "" {
+			claims = append(claims, m.Claim)
+		}
+	}
+	return claims
+}
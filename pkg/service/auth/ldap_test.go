@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLDAPConn struct {
+	passwords map[string]string
+	entries   []*ldap.Entry
+	filter    string
+}
+
+func (f *fakeLDAPConn) Bind(username, password string) error {
+	if pw, ok := f.passwords[username]; ok && pw == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, nil)
+}
+
+func (f *fakeLDAPConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	f.filter = req.Filter
+	return &ldap.SearchResult{Entries: f.entries}, nil
+}
+
+func (f *fakeLDAPConn) Close() error {
+	return nil
+}
+
+func Test_ldapProvider_Authenticate(t *testing.T) {
+	const userDN = "uid=jdoe,ou=people,dc=example,dc=com"
+
+	entry := ldap.NewEntry(userDN, map[string][]string{
+		"uid":      {"jdoe"},
+		"cn":       {"John Doe"},
+		"mail":     {"jdoe@example.com"},
+		"memberOf": {"cn=ops,ou=groups,dc=example,dc=com"},
+	})
+
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		entries    []*ldap.Entry
+		want       *providerUser
+		wantFilter string
+		wantErr    error
+	}{
+		{
+			name:     "valid credentials",
+			username: "jdoe",
+			password: "secret",
+			entries:  []*ldap.Entry{entry},
+			want: &providerUser{
+				Login:    "jdoe@ldap",
+				Name:     "John Doe",
+				Email:    "jdoe@example.com",
+				Provider: "ldap",
+				Claims: map[string]any{
+					"uid":      []string{"jdoe"},
+					"cn":       []string{"John Doe"},
+					"mail":     []string{"jdoe@example.com"},
+					"memberOf": []string{"cn=ops,ou=groups,dc=example,dc=com"},
+				},
+			},
+			wantFilter: "(uid=jdoe)",
+		},
+		{
+			name:     "wrong password",
+			username: "jdoe",
+			password: "wrong",
+			entries:  []*ldap.Entry{entry},
+			wantErr:  errInvalidCredentials,
+		},
+		{
+			name:     "empty password is rejected before binding",
+			username: "jdoe",
+			password: "",
+			entries:  []*ldap.Entry{entry},
+			wantErr:  errInvalidCredentials,
+		},
+		{
+			name:     "unknown user",
+			username: "unknown",
+			password: "secret",
+			wantErr:  errInvalidCredentials,
+		},
+		{
+			name:       "username is escaped",
+			username:   "*)(uid=*",
+			password:   "secret",
+			wantFilter: `(uid=\2a\29\28uid=\2a)`,
+			wantErr:    errInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &fakeLDAPConn{
+				passwords: map[string]string{
+					"cn=search,dc=example,dc=com": "search-secret",
+					userDN:                        "secret",
+				},
+				entries: tt.entries,
+			}
+
+			p := &ldapProvider{
+				log: slog.Default(),
+				c: LDAPProviderConfig{
+					Name:           "ldap",
+					BindDN:         "cn=search,dc=example,dc=com",
+					BindPassword:   "search-secret",
+					BaseDN:         "ou=people,dc=example,dc=com",
+					UserFilter:     ldapDefaultUserFilter,
+					UniqueUserKey:  ldapDefaultUniqueUserKey,
+					NameAttribute:  ldapDefaultNameAttribute,
+					EmailAttribute: ldapDefaultEmailAttribute,
+				},
+				dial: func() (ldapConn, error) {
+					return conn, nil
+				},
+			}
+
+			got, err := p.Authenticate(t.Context(), tt.username, tt.password)
+			if tt.wantFilter != "" {
+				require.Equal(t, tt.wantFilter, conn.filter)
+			}
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Authenticate() diff = %s", diff)
+			}
+		})
+	}
+}
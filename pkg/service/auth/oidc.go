@@ -16,13 +16,20 @@ type provider struct {
 	pc  ProviderConfig
 }
 
+const defaultOIDCProviderName = "openid-connect"
+
 type ProviderConfig struct {
-	ClientID      string
-	ClientSecret  string
-	DiscoveryURL  string
-	EndsessionURL string
-	UniqueUserKey *string
-	TLSSkipVerify bool
+	// Name is used in the login and callback urls and as suffix of the user logins, defaults to openid-connect
+	Name          string  `yaml:"name"`
+	ClientID      string  `yaml:"client_id"`
+	ClientSecret  string  `yaml:"client_secret"`
+	DiscoveryURL  string  `yaml:"discovery_url"`
+	EndsessionURL string  `yaml:"end_session_url"`
+	UniqueUserKey *string `yaml:"unique_user_key"`
+	TLSSkipVerify bool    `yaml:"tls_skip_verify"`
+	// RedirectURLs are allowed as redirect after login in addition to the globally configured redirect urls
+	RedirectURLs   []string        `yaml:"redirect_urls"`
+	TenantMappings []TenantMapping `yaml:"tenant_mappings"`
 }
 
 func OIDCHubProvider(c ProviderConfig) authOption {
@@ -34,6 +41,12 @@ func OIDCHubProvider(c ProviderConfig) authOption {
 			log: a.log,
 			pc:  c,
 		}
+
+		settings, err := newProviderSettings(c.RedirectURLs, c.TenantMappings)
+		if err != nil {
+			return err
+		}
+
 		scopes := []string{"openid", "email", "profile"}
 
 		tlsConf := &tls.Config{
@@ -43,7 +56,7 @@ func OIDCHubProvider(c ProviderConfig) authOption {
 
 		oidc, err := openidConnect.NewCustomisedHttpClient(
 			&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConf}},
-			"", //empty name results in "openid-connect" and custom name results with "-oidc" as prefix
+			"", // the name is set below, a custom name would be suffixed with "-oidc"
 			c.ClientID,
 			c.ClientSecret,
 			a.ProviderCallbackURL(p.Name()),
@@ -54,8 +67,11 @@ func OIDCHubProvider(c ProviderConfig) authOption {
 			return fmt.Errorf("unable to initialize oidc provider: %w", err)
 		}
 
+		oidc.SetName(p.Name())
+
 		goth.UseProviders(oidc)
 		a.AddProviderBackend(p)
+		a.providerSettings[p.Name()] = settings
 
 		a.log.Info("configured oidc provider", "provider", p.Name())
 
@@ -64,7 +80,10 @@ func OIDCHubProvider(c ProviderConfig) authOption {
 }
 
 func (g *provider) Name() string {
-	return "openid-connect"
+	if g.pc.Name != "" {
+		return g.pc.Name
+	}
+	return defaultOIDCProviderName
 }
 
 func (g *provider) EndSessionRedirectURL() string {
@@ -93,6 +112,8 @@ func (g *provider) User(ctx context.Context, user goth.User) (*providerUser, err
 		Name:      user.Name,
 		Email:     user.Email,
 		AvatarUrl: user.AvatarURL,
+		Provider:  g.Name(),
+		Claims:    user.RawData,
 	}, nil
 }
 
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+type (
+	// ProvidersConfig contains the login providers which are configured through a config file,
+	// they are served in addition to the oidc hub provider configured through flags.
+	ProvidersConfig struct {
+		OIDC []ProviderConfig     `yaml:"oidc"`
+		LDAP []LDAPProviderConfig `yaml:"ldap"`
+	}
+
+	// TenantMapping makes users of a provider members of the given tenant if the claim
+	// (or ldap attribute) of the user contains one of the given values.
+	// If no values are given, every user of the provider is mapped.
+	TenantMapping struct {
+		Claim  string   `yaml:"claim"`
+		Values []string `yaml:"values"`
+		Tenant string   `yaml:"tenant"`
+		Role   string   `yaml:"role"`
+	}
+
+	providerSettings struct {
+		redirectUrls   []*url.URL
+		tenantMappings []TenantMapping
+	}
+)
+
+// LoadProvidersConfig reads the login providers from the given yaml file.
+func LoadProvidersConfig(path string) (*ProvidersConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read login providers config: %w", err)
+	}
+
+	var c ProvidersConfig
+	err = yaml.Unmarshal(raw, &c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse login providers config: %w", err)
+	}
+
+	names := map[string]bool{}
+	checkName := func(name string) error {
+		if name == "" {
+			return fmt.Errorf("login provider name must not be empty")
+		}
+		if name == "logout" {
+			return fmt.Errorf("login provider name %q is reserved", name)
+		}
+		if names[name] {
+			return fmt.Errorf("login provider %q is configured more than once", name)
+		}
+		names[name] = true
+		return nil
+	}
+
+	for _, p := range c.OIDC {
+		if err := checkName(p.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range c.LDAP {
+		if err := checkName(p.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// Providers adds all providers of the given config.
+func Providers(c *ProvidersConfig) authOption {
+	return func(a *auth) error {
+		if c == nil {
+			return nil
+		}
+
+		for _, p := range c.OIDC {
+			if _, ok := a.providerBackends[p.Name]; ok {
+				return fmt.Errorf("login provider %q is already configured", p.Name)
+			}
+			if err := OIDCHubProvider(p)(a); err != nil {
+				return fmt.Errorf("unable to add oidc provider %q: %w", p.Name, err)
+			}
+		}
+
+		for _, p := range c.LDAP {
+			if _, ok := a.providerBackends[p.Name]; ok {
+				return fmt.Errorf("login provider %q is already configured", p.Name)
+			}
+			if err := LDAPProvider(p)(a); err != nil {
+				return fmt.Errorf("unable to add ldap provider %q: %w", p.Name, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+func newProviderSettings(redirectURLs []string, mappings []TenantMapping) (*providerSettings, error) {
+	s := &providerSettings{}
+
+	for _, u := range redirectURLs {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse redirect url %w", err)
+		}
+		s.redirectUrls = append(s.redirectUrls, parsed)
+	}
+
+	for _, m := range mappings {
+		if m.Tenant == "" {
+			return nil, fmt.Errorf("tenant mapping must contain a tenant")
+		}
+		if len(m.Values) > 0 && m.Claim == "" {
+			return nil, fmt.Errorf("tenant mapping for tenant %q contains values but no claim", m.Tenant)
+		}
+		if _, err := m.tenantRole(); err != nil {
+			return nil, err
+		}
+	}
+	s.tenantMappings = mappings
+
+	return s, nil
+}
+
+func (m *TenantMapping) tenantRole() (apiv2.TenantRole, error) {
+	if m.Role == "" {
+		return apiv2.TenantRole_TENANT_ROLE_VIEWER, nil
+	}
+
+	role, ok := apiv2.TenantRole_value["TENANT_ROLE_"+strings.ToUpper(m.Role)]
+	if !ok || apiv2.TenantRole(role) == apiv2.TenantRole_TENANT_ROLE_UNSPECIFIED {
+		return apiv2.TenantRole_TENANT_ROLE_UNSPECIFIED, fmt.Errorf("tenant mapping for tenant %q contains unknown role %q", m.Tenant, m.Role)
+	}
+
+	return apiv2.TenantRole(role), nil
+}
+
+// matches returns true if the given claims of a user satisfy the mapping.
+func (m *TenantMapping) matches(claims map[string]any) bool {
+	if len(m.Values) == 0 {
+		return true
+	}
+
+	var values []string
+	switch v := claims[m.Claim].(type) {
+	case string:
+		values = []string{v}
+	case []string:
+		values = v
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	for _, v := range values {
+		for _, want := range m.Values {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantMapping_matches(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping TenantMapping
+		claims  map[string]any
+		want    bool
+	}{
+		{
+			name:    "no values match every user",
+			mapping: TenantMapping{Tenant: "partners"},
+			claims:  nil,
+			want:    true,
+		},
+		{
+			name:    "string claim",
+			mapping: TenantMapping{Tenant: "partners", Claim: "org", Values: []string{"acme"}},
+			claims:  map[string]any{"org": "ACME"},
+			want:    true,
+		},
+		{
+			name:    "oidc group claim",
+			mapping: TenantMapping{Tenant: "partners", Claim: "groups", Values: []string{"partner-admins"}},
+			claims:  map[string]any{"groups": []any{"users", "partner-admins"}},
+			want:    true,
+		},
+		{
+			name:    "ldap attribute",
+			mapping: TenantMapping{Tenant: "ops", Claim: "memberOf", Values: []string{"cn=ops,ou=groups,dc=example,dc=com"}},
+			claims:  map[string]any{"memberOf": []string{"cn=ops,ou=groups,dc=example,dc=com"}},
+			want:    true,
+		},
+		{
+			name:    "value does not match",
+			mapping: TenantMapping{Tenant: "partners", Claim: "groups", Values: []string{"partner-admins"}},
+			claims:  map[string]any{"groups": []any{"users"}},
+			want:    false,
+		},
+		{
+			name:    "claim is missing",
+			mapping: TenantMapping{Tenant: "partners", Claim: "groups", Values: []string{"partner-admins"}},
+			claims:  map[string]any{"sub": "abc"},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.mapping.matches(tt.claims))
+		})
+	}
+}
+
+func TestTenantMapping_tenantRole(t *testing.T) {
+	tests := []struct {
+		role    string
+		want    apiv2.TenantRole
+		wantErr bool
+	}{
+		{role: "", want: apiv2.TenantRole_TENANT_ROLE_VIEWER},
+		{role: "owner", want: apiv2.TenantRole_TENANT_ROLE_OWNER},
+		{role: "Editor", want: apiv2.TenantRole_TENANT_ROLE_EDITOR},
+		{role: "guest", want: apiv2.TenantRole_TENANT_ROLE_GUEST},
+		{role: "unspecified", wantErr: true},
+		{role: "superuser", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			m := &TenantMapping{Tenant: "a", Role: tt.role}
+
+			got, err := m.tenantRole()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadProvidersConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *ProvidersConfig
+		wantErr string
+	}{
+		{
+			name: "oidc and ldap providers",
+			content: `
+oidc:
+  - name: corporate
+    client_id: id
+    client_secret: secret
+    discovery_url: https://idp.example.com/.well-known/openid-configuration
+    unique_user_key: preferred_username
+    redirect_urls:
+      - https://console.example.com
+  - name: partners
+    client_id: id
+    client_secret: secret
+    discovery_url: https://partners.example.com/.well-known/openid-configuration
+    tenant_mappings:
+      - claim: groups
+        values: [acme]
+        tenant: acme
+        role: editor
+ldap:
+  - name: ldap
+    url: ldaps://ldap.example.com
+    base_dn: ou=people,dc=example,dc=com
+`,
+			want: &ProvidersConfig{
+				OIDC: []ProviderConfig{
+					{
+						Name:          "corporate",
+						ClientID:      "id",
+						ClientSecret:  "secret",
+						DiscoveryURL:  "https://idp.example.com/.well-known/openid-configuration",
+						UniqueUserKey: new("preferred_username"),
+						RedirectURLs:  []string{"https://console.example.com"},
+					},
+					{
+						Name:         "partners",
+						ClientID:     "id",
+						ClientSecret: "secret",
+						DiscoveryURL: "https://partners.example.com/.well-known/openid-configuration",
+						TenantMappings: []TenantMapping{
+							{Claim: "groups", Values: []string{"acme"}, Tenant: "acme", Role: "editor"},
+						},
+					},
+				},
+				LDAP: []LDAPProviderConfig{
+					{
+						Name:   "ldap",
+						URL:    "ldaps://ldap.example.com",
+						BaseDN: "ou=people,dc=example,dc=com",
+					},
+				},
+			},
+		},
+		{
+			name: "missing name",
+			content: `
+ldap:
+  - url: ldaps://ldap.example.com
+`,
+			wantErr: "login provider name must not be empty",
+		},
+		{
+			name: "duplicate name",
+			content: `
+oidc:
+  - name: corporate
+ldap:
+  - name: corporate
+`,
+			wantErr: `login provider "corporate" is configured more than once`,
+		},
+		{
+			name: "reserved name",
+			content: `
+oidc:
+  - name: logout
+`,
+			wantErr: `login provider name "logout" is reserved`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "providers.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+			got, err := LoadProvidersConfig(path)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -6,15 +6,18 @@ import (
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/metal-stack/metal-apiserver/pkg/redact"
 )
 
 type logInterceptor struct {
-	log *slog.Logger
+	log      *slog.Logger
+	redactor *redact.Redactor
 }
 
-func newLogRequestInterceptor(log *slog.Logger) *logInterceptor {
+func newLogRequestInterceptor(log *slog.Logger, redactor *redact.Redactor) *logInterceptor {
 	return &logInterceptor{
-		log: log,
+		log:      log,
+		redactor: redactor,
 	}
 }
 
@@ -27,7 +30,7 @@ func (i *logInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 		)
 
 		if debug {
-			log = log.With("request", req.Any())
+			log = log.With("request", i.redactor.Redact(req.Any()))
 		}
 
 		log.Info("handling unary call")
@@ -35,7 +38,7 @@ func (i *logInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 		response, err := next(ctx, req)
 
 		if debug && response != nil {
-			log = log.With("response", response.Any())
+			log = log.With("response", i.redactor.Redact(response.Any()))
 		}
 
 		if err != nil {
@@ -60,6 +63,7 @@ func (i *logInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc)
 		wrapper := &wrapper{
 			StreamingHandlerConn: conn,
 			log:                  i.log,
+			redactor:             i.redactor,
 		}
 		return next(ctx, wrapper)
 	}
@@ -67,17 +71,18 @@ func (i *logInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc)
 
 type wrapper struct {
 	connect.StreamingHandlerConn
-	log *slog.Logger
+	log      *slog.Logger
+	redactor *redact.Redactor
 }
 
 func (w *wrapper) Send(m any) error {
 	procedure := w.StreamingHandlerConn.Spec().Procedure
-	w.log.Debug("streaminghandler send called", "procedure", procedure, "message", m)
+	w.log.Debug("streaminghandler send called", "procedure", procedure, "message", w.redactor.Redact(m))
 	return w.StreamingHandlerConn.Send(m)
 }
 
 func (w *wrapper) Receive(m any) error {
 	procedure := w.StreamingHandlerConn.Spec().Procedure
-	w.log.Debug("streaminghandler receive called", "procedure", procedure, "message", m)
+	w.log.Debug("streaminghandler receive called", "procedure", procedure, "message", w.redactor.Redact(m))
 	return w.StreamingHandlerConn.Receive(m)
 }
@@ -10,6 +10,7 @@ import (
 
 	"connectrpc.com/connect"
 	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/redact"
 
 	"github.com/metal-stack/api/go/client"
 
@@ -60,7 +61,7 @@ func Test_logInterceptor_AuditingCtx(t *testing.T) {
 			var (
 				buf            bytes.Buffer
 				logger         = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: tt.level}))
-				logInterceptor = newLogRequestInterceptor(logger)
+				logInterceptor = newLogRequestInterceptor(logger, redact.New(redact.DefaultFields...))
 				called         = false
 
 				interceptors = []connect.Interceptor{
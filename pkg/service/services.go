@@ -26,6 +26,7 @@ import (
 	authpkg "github.com/metal-stack/metal-apiserver/pkg/auth"
 	"github.com/metal-stack/metal-apiserver/pkg/headscale"
 	ratelimiter "github.com/metal-stack/metal-apiserver/pkg/rate-limiter"
+	"github.com/metal-stack/metal-apiserver/pkg/redact"
 	tenantclient "github.com/metal-stack/tenant-api/go/client"
 
 	"github.com/metal-stack/metal-apiserver/pkg/certs"
@@ -56,6 +57,7 @@ type Config struct {
 	OIDCEndSessionURL                   string
 	OIDCUniqueUserKey                   string
 	OIDCTLSSkipVerify                   bool
+	LoginProviders                      *authservice.ProvidersConfig
 	Datastore                           generic.Datastore
 	Repository                          *repository.Store
 	TenantClient                        tenantclient.Client
@@ -67,11 +69,17 @@ type Config struct {
 	ProviderTenant                      string
 	MaxRequestsPerMinuteToken           int
 	MaxRequestsPerMinuteUnauthenticated int
+	RateLimitPolicy                     *ratelimiter.Policy
 	IsStageDev                          bool
 	SecureCookie                        bool
 	BMCSuperuserPassword                string
 	HeadscaleClient                     *headscale.Client
+	VPNReconcileInterval                time.Duration
 	ComponentExpiration                 time.Duration
+	ImageVerifyInterval                 time.Duration
+	CapacitySnapshotInterval            time.Duration
+	MachineTimelineCleanupInterval      time.Duration
+	Redactor                            *redact.Redactor
 }
 
 type RedisConfig struct {
@@ -103,6 +111,8 @@ func New(ctx context.Context, log *slog.Logger, c Config) (*http.ServeMux, error
 		return nil, fmt.Errorf("unable to initialize authz interceptor: %w", err)
 	}
 
+	go authz.WatchCertStore(ctx)
+
 	var (
 		// We fetch projects and tenants on every request, if this hurts performance we can
 		// put the result into the context, and reuse the result in subsequent queries
@@ -126,13 +136,21 @@ func New(ctx context.Context, log *slog.Logger, c Config) (*http.ServeMux, error
 	}
 
 	var (
-		logInterceptor       = newLogRequestInterceptor(log)
+		logInterceptor       = newLogRequestInterceptor(log, c.Redactor)
 		tenantInterceptor    = tenant.NewInterceptor(log, c.TenantClient)
 		ratelimitInterceptor = ratelimiter.NewInterceptor(&ratelimiter.Config{
 			Log:                                 log,
 			RedisClient:                         c.RedisConfig.RateLimitClient,
 			MaxRequestsPerMinuteToken:           c.MaxRequestsPerMinuteToken,
 			MaxRequestsPerMinuteUnauthenticated: c.MaxRequestsPerMinuteUnauthenticated,
+			Policy:                              c.RateLimitPolicy,
+			ProjectTenant: func(ctx context.Context, projectID string) (string, error) {
+				project, err := c.Repository.UnscopedProject().Get(ctx, projectID)
+				if err != nil {
+					return "", err
+				}
+				return project.Tenant, nil
+			},
 		})
 
 		allInterceptors      = []connect.Interceptor{metricsInterceptor, logInterceptor, authz, authorizeInterceptor, ratelimitInterceptor, validationInterceptor, tenantInterceptor}
@@ -265,16 +283,24 @@ func oidcAuthHandler(log *slog.Logger, c Config) (string, http.Handler, error) {
 		return "", nil, err
 	}
 
-	_, err = auth.With(
-		authservice.OIDCHubProvider(authservice.ProviderConfig{
-			ClientID:      c.OIDCClientID,
-			ClientSecret:  c.OIDCClientSecret,
-			DiscoveryURL:  c.OIDCDiscoveryURL,
-			EndsessionURL: c.OIDCEndSessionURL,
-			UniqueUserKey: &c.OIDCUniqueUserKey,
-			TLSSkipVerify: c.OIDCTLSSkipVerify,
-		}),
-	)
+	// the oidc hub provider is only optional if further login providers are configured
+	if c.OIDCClientID != "" || c.LoginProviders == nil {
+		_, err = auth.With(
+			authservice.OIDCHubProvider(authservice.ProviderConfig{
+				ClientID:      c.OIDCClientID,
+				ClientSecret:  c.OIDCClientSecret,
+				DiscoveryURL:  c.OIDCDiscoveryURL,
+				EndsessionURL: c.OIDCEndSessionURL,
+				UniqueUserKey: &c.OIDCUniqueUserKey,
+				TLSSkipVerify: c.OIDCTLSSkipVerify,
+			}),
+		)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	_, err = auth.With(authservice.Providers(c.LoginProviders))
 	if err != nil {
 		return "", nil, err
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/metal-stack/metal-apiserver/pkg/async/queue"
 	"github.com/metal-stack/metal-apiserver/pkg/async/task"
 	"github.com/metal-stack/metal-apiserver/pkg/certs"
+	"github.com/metal-stack/metal-apiserver/pkg/console"
 	"github.com/metal-stack/metal-apiserver/pkg/db/generic"
 	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
 	"github.com/metal-stack/metal-apiserver/pkg/db/queries"
@@ -89,6 +90,9 @@ type (
 	testOptRenewCertBeforeExpiration struct {
 		renew *time.Duration
 	}
+	testOptMachineTimelineRetention struct {
+		retention time.Duration
+	}
 )
 
 // WithPostgres if set to true a postgres database container is started, defaults to false.
@@ -138,6 +142,13 @@ func WithRenewCertBeforeExpiration(renew *time.Duration) *testOptRenewCertBefore
 	}
 }
 
+// WithMachineTimelineRetention sets the duration after which machine timeline entries are deleted, defaults to forever.
+func WithMachineTimelineRetention(retention time.Duration) *testOptMachineTimelineRetention {
+	return &testOptMachineTimelineRetention{
+		retention: retention,
+	}
+}
+
 func StartRepositoryWithCleanup(t testing.TB, log *slog.Logger, testOpts ...testOpt) (*testStore, func()) {
 	var (
 		withPostgres   = false
@@ -148,6 +159,7 @@ func StartRepositoryWithCleanup(t testing.TB, log *slog.Logger, testOpts ...test
 
 		providerTenant            = DefaultProviderTenant
 		renewCertBeforeExpiration *time.Duration
+		machineTimelineRetention  time.Duration
 	)
 
 	for _, opt := range testOpts {
@@ -166,6 +178,8 @@ func StartRepositoryWithCleanup(t testing.TB, log *slog.Logger, testOpts ...test
 			providerTenant = o.t
 		case *testOptRenewCertBeforeExpiration:
 			renewCertBeforeExpiration = o.renew
+		case *testOptMachineTimelineRetention:
+			machineTimelineRetention = o.retention
 		default:
 			t.Errorf("unsupported test option: %T", o)
 		}
@@ -243,6 +257,7 @@ func StartRepositoryWithCleanup(t testing.TB, log *slog.Logger, testOpts ...test
 		Queue:                 queue,
 		Component:             vc, // Use same valkey instance as queue for tests
 		Auditing:              auditingBackend,
+		AuditBackends:         []auditing.Auditing{auditingBackend},
 		HeadscaleClient:       hc,
 		TokenConfig: repository.TokenConfig{
 			TokenStore:     tokenStore,
@@ -250,6 +265,16 @@ func StartRepositoryWithCleanup(t testing.TB, log *slog.Logger, testOpts ...test
 			ProviderTenant: providerTenant,
 			Issuer:         TokenIssuer,
 		},
+		InviteConfig: repository.InviteConfig{
+			ProjectInviteStore: projectInviteStore,
+			TenantInviteStore:  tenantInviteStore,
+		},
+		ConsoleConfig: repository.ConsoleConfig{
+			TicketStore: console.NewRedisStore(rc),
+		},
+		TimelineConfig: repository.TimelineConfig{
+			Retention: machineTimelineRetention,
+		},
 	}
 
 	repo := repository.New(config)
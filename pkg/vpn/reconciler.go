@@ -0,0 +1,183 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/db/generic"
+	"github.com/metal-stack/metal-apiserver/pkg/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	reconcilerLockKey        = "vpn-reconciler"
+	defaultReconcileInterval = time.Minute
+)
+
+var (
+	// vpnMachines is only reported by the replica which reconciles, the other replicas reset it
+	vpnMachines = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "metal_apiserver",
+		Subsystem: "vpn",
+		Name:      "machines",
+		Help:      "number of allocated machines with vpn, partitioned by their connection state",
+	}, []string{"state"})
+
+	vpnStaleNodesRemoved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "metal_apiserver",
+		Subsystem: "vpn",
+		Name:      "stale_nodes_removed_total",
+		Help:      "number of headscale nodes which were removed because their machine is not allocated or was deleted",
+	})
+)
+
+type (
+	ReconcilerConfig struct {
+		Log  *slog.Logger
+		Repo *repository.Store
+		// Datastore is used to acquire the shared mutex, only the replica holding it reconciles
+		Datastore generic.Datastore
+		// Interval between two reconciliations, defaults to one minute
+		Interval time.Duration
+	}
+
+	// Reconciler continuously updates the vpn state of allocated machines from the headscale nodes
+	// and removes nodes of machines which are not allocated anymore or were deleted.
+	Reconciler struct {
+		log      *slog.Logger
+		repo     *repository.Store
+		ds       generic.Datastore
+		interval time.Duration
+	}
+)
+
+func NewReconciler(c ReconcilerConfig) *Reconciler {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = defaultReconcileInterval
+	}
+
+	return &Reconciler{
+		log:      c.Log.WithGroup("vpn-reconciler"),
+		repo:     c.Repo,
+		ds:       c.Datastore,
+		interval: interval,
+	}
+}
+
+// Run reconciles on every interval until the context is done.
+func (r *Reconciler) Run(ctx context.Context) {
+	r.log.Info("starting vpn reconciler", "interval", r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reconcileIfLocked(ctx)
+		case <-ctx.Done():
+			r.log.Info("stopping vpn reconciler")
+			return
+		}
+	}
+}
+
+func (r *Reconciler) reconcileIfLocked(ctx context.Context) {
+	err := r.ds.Lock(ctx, reconcilerLockKey, generic.NewLockOptAcquireTimeout(time.Second), generic.NewLockOptExpirationTimeout(r.interval))
+	if err != nil {
+		r.log.Debug("vpn reconciliation is done by another replica, skipping", "error", err)
+		// the values of the last reconciliation of this replica would be stale
+		vpnMachines.Reset()
+		return
+	}
+	defer r.ds.Unlock(ctx, reconcilerLockKey)
+
+	err = r.Reconcile(ctx)
+	if err != nil {
+		r.log.Error("unable to reconcile vpn nodes", "error", err)
+	}
+}
+
+// Reconcile updates the vpn connected state and ips of all allocated machines with vpn and
+// deletes the headscale nodes of machines which are not allocated with vpn in the node's project anymore
+// or which do not exist anymore. Nodes whose name is not a machine uuid are left untouched.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	ms, err := r.repo.UnscopedMachine().List(ctx, &apiv2.MachineQuery{})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	listNodesResp, err := r.repo.UnscopedVPN().ListNodes(ctx, &adminv2.VPNServiceListNodesRequest{})
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs         []error
+		machines     = map[string]*apiv2.Machine{}
+		connected    int
+		disconnected int
+	)
+
+	for _, m := range ms {
+		machines[m.Uuid] = m
+
+		if m.Allocation == nil || m.Allocation.Vpn == nil {
+			continue
+		}
+
+		node, ok := findNode(listNodesResp.Nodes, m)
+		if ok && node.Online {
+			connected++
+		} else {
+			disconnected++
+		}
+	}
+
+	_, err = updateVPNConnected(ctx, r.log, r.repo, ms, listNodesResp.Nodes)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, node := range listNodesResp.Nodes {
+		if _, err := uuid.Parse(node.Name); err != nil {
+			// not a machine
+			continue
+		}
+
+		m, ok := machines[node.Name]
+		if ok && m.Allocation != nil && m.Allocation.Vpn != nil && m.Allocation.Project == node.Project {
+			continue
+		}
+
+		_, err := r.repo.UnscopedVPN().DeleteNode(ctx, node.Name, node.Project)
+		if err != nil {
+			errs = append(errs, err)
+			r.log.Error("unable to delete stale vpn node, continue anyway", "machine", node.Name, "project", node.Project, "error", err)
+			continue
+		}
+
+		vpnStaleNodesRemoved.Inc()
+		r.log.Info("deleted stale vpn node", "machine", node.Name, "project", node.Project)
+	}
+
+	vpnMachines.WithLabelValues("connected").Set(float64(connected))
+	vpnMachines.WithLabelValues("disconnected").Set(float64(disconnected))
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred when reconciling vpn nodes:%w", errors.Join(errs...))
+	}
+
+	return nil
+}
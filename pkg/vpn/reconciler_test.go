@@ -0,0 +1,105 @@
+package vpn_test
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	adminv2 "github.com/metal-stack/api/go/metalstack/admin/v2"
+	apiv2 "github.com/metal-stack/api/go/metalstack/api/v2"
+	"github.com/metal-stack/metal-apiserver/pkg/db/metal"
+	"github.com/metal-stack/metal-apiserver/pkg/test"
+	"github.com/metal-stack/metal-apiserver/pkg/vpn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func Test_Reconciler_Reconcile(t *testing.T) {
+	t.Parallel()
+
+	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := t.Context()
+
+	testStore, repocloser := test.StartRepositoryWithCleanup(t, log, test.WithHeadscale(true))
+	defer repocloser()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "a image")
+	}))
+
+	validURL := ts.URL
+	defer ts.Close()
+
+	test.CreateTenants(t, testStore, []*apiv2.TenantServiceCreateRequest{{Name: "t1"}})
+	test.CreateProjects(t, testStore, []*apiv2.ProjectServiceCreateRequest{{Name: p1, Login: "t1"}})
+	test.CreatePartitions(t, testStore, []*adminv2.PartitionServiceCreateRequest{
+		{
+			Partition: &apiv2.Partition{Id: "partition-1", BootConfiguration: &apiv2.PartitionBootConfiguration{ImageUrl: validURL, KernelUrl: validURL}},
+		},
+	})
+	test.CreateSizes(t, testStore, []*adminv2.SizeServiceCreateRequest{
+		{
+			Size: &apiv2.Size{Id: "c1-large-x86"},
+		},
+	})
+	test.CreateImages(t, testStore, []*adminv2.ImageServiceCreateRequest{
+		{Image: &apiv2.Image{Id: "debian-12", Url: validURL, Features: []apiv2.ImageFeature{apiv2.ImageFeature_IMAGE_FEATURE_MACHINE}}},
+	})
+
+	key, err := testStore.UnscopedVPN().CreateAuthKey(ctx, &adminv2.VPNServiceAuthKeyRequest{
+		Project:   p1,
+		Ephemeral: true,
+		Expires:   durationpb.New(time.Minute),
+	})
+	require.NoError(t, err)
+
+	connectVPNClient(t, m1, testStore.GetHeadscaleControllerURL(), key.AuthKey)
+	connectVPNClient(t, m2, testStore.GetHeadscaleControllerURL(), key.AuthKey)
+	// the machine of this node was deleted
+	connectVPNClient(t, "00000000-0000-0000-0000-000000000003", testStore.GetHeadscaleControllerURL(), key.AuthKey)
+	// not a machine
+	connectVPNClient(t, "operator", testStore.GetHeadscaleControllerURL(), key.AuthKey)
+
+	test.CreateMachines(t, testStore, []*metal.Machine{
+		{
+			Base:        metal.Base{ID: m1},
+			PartitionID: "partition-1", SizeID: "c1-large-x86",
+			Allocation: &metal.MachineAllocation{Project: p1, ImageID: "debian-12", VPN: &metal.MachineVPN{ControlPlaneAddress: testStore.UnscopedVPN().ControlPlaneAddress()}},
+		},
+		{
+			// was freed but the vpn node is still present
+			Base:        metal.Base{ID: m2},
+			PartitionID: "partition-1", SizeID: "c1-large-x86",
+		},
+	})
+
+	reconciler := vpn.NewReconciler(vpn.ReconcilerConfig{
+		Log:       log,
+		Repo:      testStore.Store,
+		Datastore: testStore.GetDatastore(),
+	})
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		err := reconciler.Reconcile(ctx)
+		require.NoError(c, err)
+
+		m, err := testStore.UnscopedMachine().Get(ctx, m1)
+		require.NoError(c, err)
+		require.True(c, m.Allocation.Vpn.Connected)
+		require.NotEmpty(c, m.Allocation.Vpn.Ips)
+	}, 30*time.Second, 1*time.Second)
+
+	resp, err := testStore.UnscopedVPN().ListNodes(ctx, &adminv2.VPNServiceListNodesRequest{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, node := range resp.Nodes {
+		names = append(names, node.Name)
+	}
+	require.ElementsMatch(t, []string{m1, "operator"}, names)
+}
@@ -33,6 +33,11 @@ func EvaluateVPNConnected(ctx context.Context, log *slog.Logger, repo *repositor
 		return nil, err
 	}
 
+	return updateVPNConnected(ctx, log, repo, ms, listNodesResp.Nodes)
+}
+
+// updateVPNConnected stores the connected state and ips of the matching vpn node at every allocated machine with vpn.
+func updateVPNConnected(ctx context.Context, log *slog.Logger, repo *repository.Store, ms []*apiv2.Machine, nodes []*apiv2.VPNNode) ([]*apiv2.Machine, error) {
 	var (
 		errs            []error
 		updatedMachines []*apiv2.Machine
@@ -42,9 +47,7 @@ func EvaluateVPNConnected(ctx context.Context, log *slog.Logger, repo *repositor
 			continue
 		}
 
-		node, ok := lo.Find(listNodesResp.Nodes, func(node *apiv2.VPNNode) bool {
-			return node.Name == m.Uuid && node.Project == m.Allocation.Project
-		})
+		node, ok := findNode(nodes, m)
 		if !ok {
 			continue
 		}
@@ -53,7 +56,7 @@ func EvaluateVPNConnected(ctx context.Context, log *slog.Logger, repo *repositor
 		ips := node.IpAddresses
 
 		if m.Allocation.Vpn.Connected == connected && slices.Equal(m.Allocation.Vpn.Ips, ips) {
-			log.Info("not updating vpn because already up-to-date", "machine", m.Uuid, "connected", connected, "ips", ips)
+			log.Debug("not updating vpn because already up-to-date", "machine", m.Uuid, "connected", connected, "ips", ips)
 			continue
 		}
 
@@ -74,3 +77,9 @@ func EvaluateVPNConnected(ctx context.Context, log *slog.Logger, repo *repositor
 
 	return updatedMachines, nil
 }
+
+func findNode(nodes []*apiv2.VPNNode, m *apiv2.Machine) (*apiv2.VPNNode, bool) {
+	return lo.Find(nodes, func(node *apiv2.VPNNode) bool {
+		return node.Name == m.Uuid && node.Project == m.Allocation.Project
+	})
+}